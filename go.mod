@@ -6,8 +6,10 @@ require (
 	github.com/codeshelldev/gotl/pkg/configutils v0.0.26
 	github.com/codeshelldev/gotl/pkg/jsonutils v0.0.4
 	github.com/codeshelldev/gotl/pkg/query v0.0.4
+	github.com/codeshelldev/gotl/pkg/scheduler v0.0.1
 	github.com/codeshelldev/gotl/pkg/stringutils v0.0.8
 	github.com/codeshelldev/gotl/pkg/templating v0.0.17
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -22,3 +24,9 @@ require (
 	github.com/fsnotify/fsnotify v1.9.0 // indirect
 	golang.org/x/sys v0.42.0 // indirect
 )
+
+// changed in the tree (not published yet)
+replace (
+	github.com/codeshelldev/gotl/pkg/configutils => ./pkg/configutils
+	github.com/codeshelldev/gotl/pkg/scheduler => ./pkg/scheduler
+)
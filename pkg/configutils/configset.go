@@ -0,0 +1,425 @@
+package configutils
+
+import (
+	"errors"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/knadh/koanf/v2"
+)
+
+const DEFAULT_PARENT_KEY = "extends"
+
+type TenantError struct {
+	Tenant	string
+	Err		error
+}
+
+func (err *TenantError) Error() string {
+	return "tenant " + err.Tenant + ": " + err.Err.Error()
+}
+
+func (err *TenantError) Unwrap() error {
+	return err.Err
+}
+
+type InheritanceCycleError struct {
+	Chain	[]string
+}
+
+func (err *InheritanceCycleError) Error() string {
+	return "config inheritance cycle: " + strings.Join(err.Chain, " -> ")
+}
+
+// Named Configs that inherit from a parent (or Base if they have none)
+type ConfigSet struct {
+	Base				*Config
+	ParentKey			string
+	ReloadFunc			func(name string)
+	ReloadErrorFunc		func(name string, err error)
+	configs				map[string]*Config
+	parents				map[string]string
+	overrides			map[string]*Config
+	mutex				sync.RWMutex
+}
+
+// Create a New ConfigSet with base as root of every inheritance chain
+func NewSet(base *Config) *ConfigSet {
+	if base == nil {
+		base = New()
+	}
+
+	return &ConfigSet{
+		Base: base,
+		ParentKey: DEFAULT_PARENT_KEY,
+		configs: map[string]*Config{},
+		parents: map[string]string{},
+		overrides: map[string]*Config{},
+	}
+}
+
+// Set ReloadFunc, called with the name of every reloaded Config and its descendants
+func (set *ConfigSet) OnReload(reloadFunc func(name string)) {
+	set.mutex.Lock()
+	defer set.mutex.Unlock()
+
+	set.ReloadFunc = reloadFunc
+}
+
+// Set ReloadErrorFunc, called when a reload fails and the previous config is kept
+func (set *ConfigSet) OnReloadError(reloadErrorFunc func(name string, err error)) {
+	set.mutex.Lock()
+	defer set.mutex.Unlock()
+
+	set.ReloadErrorFunc = reloadErrorFunc
+}
+
+// Add Config with parent (empty parent => Base)
+func (set *ConfigSet) Add(name string, parent string, config *Config) {
+	set.mutex.Lock()
+	defer set.mutex.Unlock()
+
+	set.configs[name] = config
+	set.parents[name] = parent
+}
+
+// Remove Config and its override
+func (set *ConfigSet) Remove(name string) {
+	set.mutex.Lock()
+	defer set.mutex.Unlock()
+
+	delete(set.configs, name)
+	delete(set.parents, name)
+	delete(set.overrides, name)
+}
+
+// Load every file inside of dir as Config named after the file (without ext),
+// the parent is read from ParentKey and files are watched if ReloadFunc is set
+func (set *ConfigSet) LoadDir(dir string, ext string, parser koanf.Parser, transform func(*Config, string)) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*" + ext))
+
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+
+	for _, f := range files {
+		name := strings.TrimSuffix(filepath.Base(f), filepath.Ext(f))
+
+		err := set.LoadFile(name, f, parser, transform)
+
+		if err != nil {
+			errs = append(errs, &TenantError{
+				Tenant: name,
+				Err: err,
+			})
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Load file as Config name, the parent is read from ParentKey
+func (set *ConfigSet) LoadFile(name string, path string, parser koanf.Parser, transform func(*Config, string)) error {
+	config := New()
+
+	config.UseKeyring(set.Base.getKeyring())
+
+	steps := []ReloadStep{
+		FileStep(path, parser),
+	}
+
+	if transform != nil {
+		steps = append(steps, func(staged *Config) error {
+			transform(staged, path)
+
+			return nil
+		})
+	}
+
+	set.mutex.RLock()
+	watch := set.ReloadFunc != nil
+	set.mutex.RUnlock()
+
+	if watch {
+		config.OnReload(func(string) {
+			err := config.Reload(steps...)
+
+			if err != nil {
+				set.reportReloadError(name, err)
+				return
+			}
+
+			set.store(name, config)
+			set.notifyReload(name)
+		})
+	}
+
+	err := config.Reload(steps...)
+
+	if err != nil {
+		return err
+	}
+
+	set.store(name, config)
+
+	return nil
+}
+
+// Set per-Config override, loaded on top of the inherited Config
+func (set *ConfigSet) SetOverride(name string, data any, path string) error {
+	override := New()
+
+	err := override.Load(data, path)
+
+	if err != nil {
+		return err
+	}
+
+	set.mutex.Lock()
+	set.overrides[name] = override
+	set.mutex.Unlock()
+
+	set.notifyReload(name)
+
+	return nil
+}
+
+// Remove per-Config override
+func (set *ConfigSet) ClearOverride(name string) {
+	set.mutex.Lock()
+	delete(set.overrides, name)
+	set.mutex.Unlock()
+
+	set.notifyReload(name)
+}
+
+// Sorted names of all Configs
+func (set *ConfigSet) Names() []string {
+	set.mutex.RLock()
+	defer set.mutex.RUnlock()
+
+	names := make([]string, 0, len(set.configs))
+
+	for name := range set.configs {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// Get own (not inherited) Config
+func (set *ConfigSet) Get(name string) (*Config, bool) {
+	set.mutex.RLock()
+	defer set.mutex.RUnlock()
+
+	config, ok := set.configs[name]
+
+	return config, ok
+}
+
+// Get inheritance chain of name, from the root ancestor to name
+func (set *ConfigSet) Chain(name string) ([]string, error) {
+	set.mutex.RLock()
+	defer set.mutex.RUnlock()
+
+	return set.chainLocked(name)
+}
+
+func (set *ConfigSet) chainLocked(name string) ([]string, error) {
+	chain := []string{}
+	seen := map[string]bool{}
+
+	for current := name; current != ""; current = set.parents[current] {
+		if seen[current] {
+			start := 0
+
+			for i, chainName := range chain {
+				if chainName == current {
+					start = i
+					break
+				}
+			}
+
+			cycle := append([]string{}, chain[start:]...)
+
+			return nil, &InheritanceCycleError{
+				Chain: append(cycle, current),
+			}
+		}
+
+		_, exists := set.configs[current]
+
+		if !exists {
+			return nil, errors.New("parent " + current + " not found")
+		}
+
+		seen[current] = true
+		chain = append(chain, current)
+	}
+
+	// root first
+	for i, j := 0, len(chain) - 1; i < j; i, j = i + 1, j - 1 {
+		chain[i], chain[j] = chain[j], chain[i]
+	}
+
+	return chain, nil
+}
+
+// Get names of Configs inheriting (directly or indirectly) from name
+func (set *ConfigSet) Descendants(name string) []string {
+	set.mutex.RLock()
+	defer set.mutex.RUnlock()
+
+	descendants := []string{}
+
+	for other := range set.configs {
+		if other == name {
+			continue
+		}
+
+		chain, err := set.chainLocked(other)
+
+		if err != nil {
+			continue
+		}
+
+		for _, ancestor := range chain {
+			if ancestor == name {
+				descendants = append(descendants, other)
+				break
+			}
+		}
+	}
+
+	sort.Strings(descendants)
+
+	return descendants
+}
+
+// Resolve Config by merging Base, ancestors, Config and its override (in that order)
+func (set *ConfigSet) Resolve(name string) (*Config, error) {
+	set.mutex.RLock()
+	defer set.mutex.RUnlock()
+
+	chain, err := set.chainLocked(name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	configs := []*Config{
+		set.Base,
+	}
+
+	for _, ancestor := range chain {
+		configs = append(configs, set.configs[ancestor])
+	}
+
+	override, ok := set.overrides[name]
+
+	if ok {
+		configs = append(configs, override)
+	}
+
+	layers := make([]*koanf.Koanf, 0, len(configs))
+
+	for _, config := range configs {
+		layers = append(layers, config.Snapshot())
+	}
+
+	resolved := NewWith(set.Base.Snapshot().Delim(), nil)
+
+	err = resolved.MergeLayers(layers...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// parent is only needed to build the chain
+	resolved.Layer.Delete(set.ParentKey)
+
+	// secrets of every layer stay secret
+	for _, config := range configs {
+		for _, secret := range config.secretPaths() {
+			resolved.MarkSecret(secret)
+		}
+	}
+
+	return resolved, nil
+}
+
+// Resolve every Config and unmarshal path into T, errors are attributed per Config (see TenantError)
+func UnmarshalSet[T any](set *ConfigSet, path string) (map[string]T, error) {
+	out := map[string]T{}
+
+	var errs []error
+
+	for _, name := range set.Names() {
+		var schema T
+
+		resolved, err := set.Resolve(name)
+
+		if err == nil {
+			err = resolved.Unmarshal(path, &schema)
+		}
+
+		if err != nil {
+			errs = append(errs, &TenantError{
+				Tenant: name,
+				Err: err,
+			})
+
+			continue
+		}
+
+		out[name] = schema
+	}
+
+	return out, errors.Join(errs...)
+}
+
+// Store loaded Config, its parent is read from ParentKey
+func (set *ConfigSet) store(name string, config *Config) {
+	set.mutex.Lock()
+	defer set.mutex.Unlock()
+
+	parent, _ := config.Get(set.ParentKey).(string)
+
+	set.configs[name] = config
+	set.parents[name] = parent
+}
+
+func (set *ConfigSet) notifyReload(name string) {
+	set.mutex.RLock()
+	reloadFunc := set.ReloadFunc
+	set.mutex.RUnlock()
+
+	if reloadFunc == nil {
+		return
+	}
+
+	reloadFunc(name)
+
+	for _, descendant := range set.Descendants(name) {
+		reloadFunc(descendant)
+	}
+}
+
+func (set *ConfigSet) reportReloadError(name string, err error) {
+	set.mutex.RLock()
+	reloadErrorFunc := set.ReloadErrorFunc
+	set.mutex.RUnlock()
+
+	if reloadErrorFunc != nil {
+		reloadErrorFunc(name, &TenantError{
+			Tenant: name,
+			Err: err,
+		})
+	}
+}
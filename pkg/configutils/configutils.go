@@ -5,8 +5,10 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"text/template"
 
 	t "github.com/codeshelldev/gotl/pkg/configutils/types"
@@ -18,15 +20,28 @@ import (
 	"github.com/knadh/koanf/v2"
 )
 
-var configLock sync.Mutex
-
 var DELIM string = "."
 
+const (
+	TEMPLATE_LEFT_DELIM = "${{"
+	TEMPLATE_RIGHT_DELIM = "}}"
+)
+
 var DEFAULT_HOOKS = []mapstructure.DecodeHookFunc{t.NilSentinelHook}
 
+// Layers are copy-on-write: mutations build a new layer and swap it in,
+// so a layer obtained from Snapshot() is never modified afterwards.
+// Direct access to Layer (and the exported funcs) is not synchronized, prefer Snapshot() and the setters
 type Config struct {
 	Layer *koanf.Koanf
 	ReloadFunc func(string)
+	ReloadErrorFunc func(error)
+	Keyring *Keyring
+	secrets map[string]bool
+	provenance map[string]string
+	watchers map[string]*watch
+	mutex sync.RWMutex
+	reloadLock *sync.Mutex
 }
 
 // Create a New Config with Args
@@ -34,6 +49,7 @@ func NewWith(delim string, reloadFunc func(string)) *Config {
 	return &Config{
 		Layer: koanf.New(delim),
 		ReloadFunc: reloadFunc,
+		reloadLock: &sync.Mutex{},
 	}
 }
 
@@ -42,30 +58,68 @@ func New() *Config {
 	return &Config{
 		Layer: koanf.New(DELIM),
 		ReloadFunc: nil,
+		reloadLock: &sync.Mutex{},
 	}
 }
 
 // Set ReloadFunc
 func (config *Config) OnReload(reloadFunc func(string)) {
+	config.mutex.Lock()
+	defer config.mutex.Unlock()
+
 	config.ReloadFunc = reloadFunc
 }
 
+// Set ReloadErrorFunc, called when a reload fails and the previous config is kept
+func (config *Config) OnReloadError(reloadErrorFunc func(error)) {
+	config.mutex.Lock()
+	defer config.mutex.Unlock()
+
+	config.ReloadErrorFunc = reloadErrorFunc
+}
+
+// Set Keyring, used for decrypting `enc:v1:` values at load
+func (config *Config) UseKeyring(keyring *Keyring) {
+	config.mutex.Lock()
+	defer config.mutex.Unlock()
+
+	config.Keyring = keyring
+}
+
+// Get current layer, safe for concurrent use since layers are never modified after being swapped in
+func (config *Config) Snapshot() *koanf.Koanf {
+	config.mutex.RLock()
+	defer config.mutex.RUnlock()
+
+	return config.Layer
+}
+
+// Get value at path from current layer
+func (config *Config) Get(path string) any {
+	return config.Snapshot().Get(path)
+}
+
+// Check if path exists in current layer
+func (config *Config) Exists(path string) bool {
+	return config.Snapshot().Exists(path)
+}
+
 // Watch file with file provider
 func (config *Config) WatchFile(fileProvider *file.File, path string) {
-	watchFile(fileProvider, path, config.ReloadFunc)
+	config.watch(fileProvider, path)
 }
 
 // Load file with parser into Config
 func (config *Config) LoadFile(path string, parser koanf.Parser) (*file.File, error) {
 	f := file.Provider(path)
 
-	err := config.Layer.Load(f, parser)
-	
+	err := config.load(f, parser, "file:" + path)
+
 	if err != nil {
 		return nil, err
 	}
 
-	if config.ReloadFunc != nil {
+	if config.getReloadFunc() != nil {
 		config.WatchFile(f, path)
 	}
 
@@ -81,32 +135,87 @@ func (config *Config) LoadDir(path string, dir string, ext string, parser koanf.
 	}
 
 	var array []any
+	var secrets []string
+
+	providers := map[string]*file.File{}
+
+	keyring := config.getKeyring()
 
 	for _, f := range files {
 		tmp := New()
 
-		tmp.OnReload(config.ReloadFunc)
+		tmp.UseKeyring(keyring)
+
+		provider := file.Provider(f)
 
-		_, err := tmp.LoadFile(f, parser)
+		err := tmp.load(provider, parser, "file:" + f)
 
 		if err != nil {
 			return err
 		}
 
+		providers[f] = provider
+
 		transform(tmp, f)
 
-		array = append(array, tmp.Layer.Raw())
+		// values decrypted by tmp stay secret in Config
+		prefix := strconv.Itoa(len(array))
+
+		if path != "" {
+			prefix = joinPaths(path, prefix)
+		}
+
+		for _, secret := range tmp.secretPaths() {
+			secrets = append(secrets, joinPaths(prefix, secret))
+		}
+
+		array = append(array, tmp.Snapshot().Raw())
+	}
+
+	err = config.loadData(array, path, "dir:" + dir)
+
+	if err != nil {
+		return err
+	}
+
+	// files are watched by Config, not by tmp
+	if config.getReloadFunc() != nil {
+		for f, provider := range providers {
+			config.WatchFile(provider, f)
+		}
+	}
+
+	config.mutex.Lock()
+	defer config.mutex.Unlock()
+
+	for _, secret := range secrets {
+		config.markSecretLocked(secret)
 	}
 
-	return config.Load(array, path)
+	return nil
 }
 
 // Load data into Config path
 func (config *Config) Load(data any, path string) error {
+	return config.loadData(data, path, SOURCE_DATA)
+}
+
+func (config *Config) loadData(data any, path string, source string) error {
+	res, err := wrapData(data, path)
+
+	if err != nil {
+		return err
+	}
+
+	return config.load(confmap.Provider(res, DELIM), nil, source)
+}
+
+// Wrap data into map at path (`a.b` => `{ a: { b: data } }`)
+func wrapData(data any, path string) (map[string]any, error) {
 	parts := strings.Split(path, DELIM)
 
 	if len(parts) <= 0 {
-		return errors.New("invalid path")
+		return nil, errors.New("invalid path")
 	}
 
 	res := map[string]any{}
@@ -137,7 +246,8 @@ func (config *Config) Load(data any, path string) error {
 		}
 	}
 
-	return config.Layer.Load(confmap.Provider(res, DELIM), nil)
+	// unflatten `a.b` keys
+	return confmap.Provider(res, DELIM).Read()
 }
 
 // Load environment into Config with transformFunc
@@ -146,54 +256,137 @@ func (config *Config) LoadEnv(transformFunc func(key string, value string) (stri
 		TransformFunc: transformFunc,
 	})
 
-	err := config.Layer.Load(e, nil)
+	err := config.load(e, nil, SOURCE_ENV)
 
 	return e, err
 }
 
+// Load provider into layer, decrypts values if Keyring is set and records source as provenance (if not empty)
+func (config *Config) load(provider koanf.Provider, parser koanf.Parser, source string) error {
+	// read outside of lock, providers may block on io
+	data, err := readProvider(provider, parser)
+
+	if err != nil {
+		return err
+	}
+
+	config.mutex.Lock()
+	defer config.mutex.Unlock()
+
+	return config.mergeLocked(data, source)
+}
+
+// Merge data into a copy of the current layer and swap it in, expects config.mutex to be held
+func (config *Config) mergeLocked(data map[string]any, source string) error {
+	if config.Keyring != nil {
+		_, err := DecryptData("", data, config.Keyring, config.markSecretLocked)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	next := config.Layer.Copy()
+
+	err := next.Load(rawProvider(data), nil)
+
+	if err != nil {
+		return err
+	}
+
+	config.Layer = next
+
+	if source != "" {
+		config.recordProvenance(data, source)
+	}
+
+	return nil
+}
+
 // Template Config with environment + variables
 func (config *Config) TemplateConfig(variables map[string]any) error {
-	return config.Load(config.GetTemplated(variables), "")
+	config.mutex.Lock()
+	defer config.mutex.Unlock()
+
+	templated, err := config.getTemplated(variables)
+
+	if err != nil {
+		return err
+	}
+
+	res, err := wrapData(templated, "")
+
+	if err != nil {
+		return err
+	}
+
+	// templating keeps the original provenance
+	return config.mergeLocked(res, "")
 }
 
 // Alternative to TemplateConfig(), doesn't modify the config
 func (config *Config) GetTemplated(variables map[string]any) any {
+	config.mutex.RLock()
+	defer config.mutex.RUnlock()
+
+	templated, err := config.getTemplated(variables)
+
+	if err != nil {
+		return nil
+	}
+
+	return templated
+}
+
+// Template current layer, expects config.mutex to be held
+func (config *Config) getTemplated(variables map[string]any) (any, error) {
 	data := config.Layer.All()
 
 	envMap := environMap()
 
+	tree := config.Layer.Raw()
+
 	vars := map[string]any{
 		"env": envMap,
 		"vars": variables,
+		"config": tree,
 	}
 
-	templated, err := templating.TemplateDataRecursively("", data, vars, template.New("").Delims("${{", "}}"))
+	order, err := resolveReferenceOrder(data)
 
 	if err != nil {
-		return nil
+		return nil, err
 	}
 
-	return templated
-}
+	base := template.New("").Delims(TEMPLATE_LEFT_DELIM, TEMPLATE_RIGHT_DELIM)
 
-// Get tag from scheme field by using a pointer of said field
-func GetSchemeTagByFieldPointer(config any, tag string, fieldPointer any) string {
-	v := reflect.ValueOf(config)
-	if v.Kind() == reflect.Pointer {
-		v = v.Elem()
+	templated := make(map[string]any, len(data))
+
+	// template in dependency order, so that references see templated values
+	for _, key := range order {
+		value, err := templating.TemplateDataRecursively(key, data[key], vars, base)
+
+		if err != nil {
+			return nil, err
+		}
+
+		templated[key] = value
+
+		setByPath(tree, splitPath(key), value)
 	}
 
-	fieldValue := reflect.ValueOf(fieldPointer).Elem()
+	return templated, nil
+}
 
-	for i := 0; i < v.NumField(); i++ {
-		if v.Field(i).Addr().Interface() == fieldValue.Addr().Interface() {
-			field := v.Type().Field(i)
+// Get tag from scheme field by using a pointer of said field (nested fields are supported, see ResolveFieldPointer())
+func GetSchemeTagByFieldPointer(config any, tag string, fieldPointer any) string {
+	info, ok := ResolveFieldPointer(config, fieldPointer)
 
-			return field.Tag.Get(tag)
-		}
+	if !ok {
+		return ""
 	}
 
-	return ""
+	return info.Field.Tag.Get(tag)
 }
 
 func environMap() map[string]any {
@@ -220,14 +413,21 @@ func environMap() map[string]any {
 
 // Merge layers into Config
 func (config *Config) MergeLayers(layers ...*koanf.Koanf) error {
+	config.mutex.Lock()
+	defer config.mutex.Unlock()
+
+	next := config.Layer.Copy()
+
 	for _, layer := range layers {
-		err := config.Layer.Merge(layer)
+		err := next.Merge(layer)
 
 		if err != nil {
 			return err
 		}
 	}
 
+	config.Layer = next
+
 	return nil
 }
 
@@ -247,24 +447,110 @@ func (config *Config) UnmarshalWithHooks(path string, schema any, hooks ...mapst
 }
 
 func (config *Config) UnmarshalWith(path string, schema any, c koanf.UnmarshalConf) error {
-	return config.Layer.UnmarshalWithConf(path, schema, c)
+	return config.Snapshot().UnmarshalWithConf(path, schema, c)
+}
+
+type watcher interface {
+	Watch(cb func(event any, err error)) error
+	Unwatch() error
+}
+
+func (config *Config) getReloadFunc() func(string) {
+	config.mutex.RLock()
+	defer config.mutex.RUnlock()
+
+	return config.ReloadFunc
+}
+
+func (config *Config) getKeyring() *Keyring {
+	config.mutex.RLock()
+	defer config.mutex.RUnlock()
+
+	return config.Keyring
+}
+
+func (config *Config) getReloadLock() *sync.Mutex {
+	config.mutex.Lock()
+	defer config.mutex.Unlock()
+
+	if config.reloadLock == nil {
+		config.reloadLock = &sync.Mutex{}
+	}
+
+	return config.reloadLock
 }
 
-func watchFile(f *file.File, path string, loadFunc func(string)) {
+// Watch file and call ReloadFunc on change, reloads of one Config (and its staged Configs) never run concurrently.
+// A file has at most one watcher per Config, watching it again replaces the previous watcher
+func (config *Config) watch(f watcher, path string) {
+	reloadLock := config.getReloadLock()
+
+	w := &watch{
+		f: f,
+	}
+
+	config.mutex.Lock()
+
+	config.replaceWatcherLocked(path, w)
+
+	config.mutex.Unlock()
+
 	f.Watch(func(event any, err error) {
 		if err != nil {
 			return
 		}
 
-		configLock.Lock()
-		defer configLock.Unlock()
+		reloadLock.Lock()
+		defer reloadLock.Unlock()
+
+		// replaced while waiting for the reload lock
+		if w.stopped.Load() {
+			return
+		}
 
-		f.Unwatch()
+		reloadFunc := config.getReloadFunc()
 
-		loadFunc(path)
+		if reloadFunc != nil {
+			reloadFunc(path)
+		}
 	})
 }
 
+type watch struct {
+	f		watcher
+	stopped	atomic.Bool
+}
+
+func (w *watch) stop() {
+	w.stopped.Store(true)
+	w.f.Unwatch()
+}
+
+func (config *Config) replaceWatcherLocked(path string, w *watch) {
+	previous, exists := config.watchers[path]
+
+	if exists && previous != w {
+		previous.stop()
+	}
+
+	if config.watchers == nil {
+		config.watchers = map[string]*watch{}
+	}
+
+	config.watchers[path] = w
+}
+
+func (config *Config) unwatchAll() {
+	config.mutex.Lock()
+	defer config.mutex.Unlock()
+
+	for _, w := range config.watchers {
+		w.stop()
+	}
+
+	config.watchers = nil
+}
+
 // Walks schema and calls fn for every field with its path, schema field and raw + typed value
 func WalkSchema(schema reflect.Type, value reflect.Value, raw any, path []string, fn func(path string, field reflect.StructField, raw any, value reflect.Value)) {
 	if schema == nil {
@@ -0,0 +1,352 @@
+package configutils
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/knadh/koanf/providers/env/v2"
+	"github.com/knadh/koanf/providers/file"
+)
+
+type DotEnv struct {
+	path			string
+	delim			string
+	transform		func(key string, value string) (string, any)
+	file			*file.File
+}
+
+// Create dotenv provider, transformFunc is applied the same way as in LoadEnv()
+func DotEnvProvider(path string, delim string, transformFunc func(key string, value string) (string, any)) *DotEnv {
+	return &DotEnv{
+		path: path,
+		delim: delim,
+		transform: transformFunc,
+		file: file.Provider(path),
+	}
+}
+
+func (dotEnv *DotEnv) ReadBytes() ([]byte, error) {
+	return nil, errors.New("dotenv provider does not support this method")
+}
+
+func (dotEnv *DotEnv) Read() (map[string]any, error) {
+	data, err := dotEnv.file.ReadBytes()
+
+	if err != nil {
+		return nil, err
+	}
+
+	vars, err := ParseDotEnv(string(data), os.LookupEnv)
+
+	if err != nil {
+		return nil, errors.New(dotEnv.path + ": " + err.Error())
+	}
+
+	environ := make([]string, 0, len(vars))
+
+	for key, value := range vars {
+		environ = append(environ, key + "=" + value)
+	}
+
+	e := env.Provider(dotEnv.delim, env.Opt{
+		TransformFunc: dotEnv.transform,
+		EnvironFunc: func() []string {
+			return environ
+		},
+	})
+
+	return e.Read()
+}
+
+// Watch dotenv file for changes
+func (dotEnv *DotEnv) Watch(cb func(event any, err error)) error {
+	return dotEnv.file.Watch(cb)
+}
+
+// Stop watching dotenv file
+func (dotEnv *DotEnv) Unwatch() error {
+	return dotEnv.file.Unwatch()
+}
+
+// Load dotenv file into Config with transformFunc
+func (config *Config) LoadDotEnv(path string, transformFunc func(key string, value string) (string, any)) (*DotEnv, error) {
+	dotEnv := DotEnvProvider(path, DELIM, transformFunc)
+
+	err := config.load(dotEnv, nil, "dotenv:" + path)
+
+	// keep watching broken files, so that fixes get picked up
+	if config.getReloadFunc() != nil {
+		config.watch(dotEnv, path)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return dotEnv, nil
+}
+
+// Parse dotenv content, supports `export` prefixes, comments, single / double quotes, escapes,
+// multi-line values and `${VAR}`, `${VAR:-default}`, `$VAR` interpolation (file vars first, then lookup)
+func ParseDotEnv(content string, lookup func(string) (string, bool)) (map[string]string, error) {
+	vars := map[string]string{}
+
+	resolve := func(name string) (string, bool) {
+		value, ok := vars[name]
+
+		if ok {
+			return value, true
+		}
+
+		if lookup != nil {
+			return lookup(name)
+		}
+
+		return "", false
+	}
+
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+
+	line := 1
+	pos := 0
+
+	for pos < len(content) {
+		// skip whitespace and empty lines
+		for pos < len(content) && isDotEnvSpace(content[pos]) {
+			if content[pos] == '\n' {
+				line++
+			}
+
+			pos++
+		}
+
+		if pos >= len(content) {
+			break
+		}
+
+		if content[pos] == '#' {
+			pos = skipLine(content, pos)
+			continue
+		}
+
+		rest := content[pos:]
+
+		after, ok := strings.CutPrefix(rest, "export")
+
+		if ok && len(after) > 0 && (after[0] == ' ' || after[0] == '\t') {
+			pos += len("export")
+
+			for pos < len(content) && (content[pos] == ' ' || content[pos] == '\t') {
+				pos++
+			}
+		}
+
+		start := pos
+
+		for pos < len(content) && isDotEnvKeyChar(content[pos]) {
+			pos++
+		}
+
+		key := content[start:pos]
+
+		if key == "" {
+			return nil, errors.New("line " + strconv.Itoa(line) + ": invalid key")
+		}
+
+		for pos < len(content) && (content[pos] == ' ' || content[pos] == '\t') {
+			pos++
+		}
+
+		if pos >= len(content) || content[pos] != '=' {
+			return nil, errors.New("line " + strconv.Itoa(line) + ": expected = after " + key)
+		}
+
+		pos++
+
+		for pos < len(content) && (content[pos] == ' ' || content[pos] == '\t') {
+			pos++
+		}
+
+		var value string
+		var err error
+
+		startLine := line
+
+		if pos < len(content) && (content[pos] == '"' || content[pos] == '\'') {
+			quote := content[pos]
+
+			var raw string
+
+			raw, pos, line, err = readQuoted(content, pos + 1, line, quote)
+
+			if err != nil {
+				return nil, errors.New("line " + strconv.Itoa(startLine) + ": " + err.Error())
+			}
+
+			if quote == '"' {
+				value = expandDotEnv(raw, true, resolve)
+			} else {
+				value = raw
+			}
+
+			// only comments are allowed after quoted values
+			end := skipLine(content, pos)
+			trailing := strings.TrimSpace(content[pos:end])
+
+			if trailing != "" && !strings.HasPrefix(trailing, "#") {
+				return nil, errors.New("line " + strconv.Itoa(line) + ": unexpected " + trailing)
+			}
+
+			pos = end
+		} else {
+			end := skipLine(content, pos)
+			raw := content[pos:end]
+
+			// strip inline comments
+			for i := 0; i < len(raw); i++ {
+				if raw[i] == '#' && (i == 0 || raw[i - 1] == ' ' || raw[i - 1] == '\t') {
+					raw = raw[:i]
+					break
+				}
+			}
+
+			value = expandDotEnv(strings.TrimSpace(raw), false, resolve)
+
+			pos = end
+		}
+
+		vars[key] = value
+	}
+
+	return vars, nil
+}
+
+func readQuoted(content string, pos int, line int, quote byte) (string, int, int, error) {
+	var builder strings.Builder
+
+	for pos < len(content) {
+		char := content[pos]
+
+		if char == quote {
+			return builder.String(), pos + 1, line, nil
+		}
+
+		if char == '\n' {
+			line++
+		}
+
+		// keep escapes for expansion, but don't end on escaped quotes
+		if char == '\\' && quote == '"' && pos + 1 < len(content) {
+			builder.WriteByte(char)
+			builder.WriteByte(content[pos + 1])
+
+			pos += 2
+			continue
+		}
+
+		builder.WriteByte(char)
+		pos++
+	}
+
+	return "", pos, line, errors.New("unterminated quoted value")
+}
+
+func expandDotEnv(raw string, escapes bool, resolve func(string) (string, bool)) string {
+	var builder strings.Builder
+
+	for i := 0; i < len(raw); i++ {
+		char := raw[i]
+
+		if escapes && char == '\\' && i + 1 < len(raw) {
+			i++
+
+			switch raw[i] {
+			case 'n':
+				builder.WriteByte('\n')
+			case 'r':
+				builder.WriteByte('\r')
+			case 't':
+				builder.WriteByte('\t')
+			default:
+				builder.WriteByte(raw[i])
+			}
+
+			continue
+		}
+
+		if char != '$' || i + 1 >= len(raw) {
+			builder.WriteByte(char)
+			continue
+		}
+
+		if raw[i + 1] == '{' {
+			end := strings.IndexByte(raw[i:], '}')
+
+			if end < 0 {
+				builder.WriteByte(char)
+				continue
+			}
+
+			expr := raw[i + 2 : i + end]
+
+			name, fallback, hasFallback := strings.Cut(expr, ":-")
+
+			value, ok := resolve(name)
+
+			if (!ok || value == "") && hasFallback {
+				value = fallback
+			}
+
+			builder.WriteString(value)
+
+			i += end
+			continue
+		}
+
+		end := i + 1
+
+		for end < len(raw) && isDotEnvNameChar(raw[end]) {
+			end++
+		}
+
+		if end == i + 1 {
+			builder.WriteByte(char)
+			continue
+		}
+
+		value, _ := resolve(raw[i + 1 : end])
+
+		builder.WriteString(value)
+
+		i = end - 1
+	}
+
+	return builder.String()
+}
+
+func skipLine(content string, pos int) int {
+	end := strings.IndexByte(content[pos:], '\n')
+
+	if end < 0 {
+		return len(content)
+	}
+
+	return pos + end
+}
+
+func isDotEnvSpace(char byte) bool {
+	return char == ' ' || char == '\t' || char == '\n'
+}
+
+func isDotEnvNameChar(char byte) bool {
+	return char == '_' ||
+		(char >= 'a' && char <= 'z') ||
+		(char >= 'A' && char <= 'Z') ||
+		(char >= '0' && char <= '9')
+}
+
+func isDotEnvKeyChar(char byte) bool {
+	return isDotEnvNameChar(char) || char == '.' || char == '-'
+}
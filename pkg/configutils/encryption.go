@@ -0,0 +1,286 @@
+package configutils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const ENCRYPTED_PREFIX = "enc:v1:"
+
+const DEFAULT_KEY_ID = "default"
+
+type Keyring struct {
+	Primary		string
+	keys		map[string]cipher.AEAD
+	order		[]string
+}
+
+// Create empty Keyring
+func NewKeyring() *Keyring {
+	return &Keyring{
+		keys: map[string]cipher.AEAD{},
+	}
+}
+
+// Parse keys in the format `id:base64key` (separated by newlines or commas), a single `base64key` gets DEFAULT_KEY_ID.
+// The first key is the primary key, which is used for encryption
+func ParseKeys(str string) (*Keyring, error) {
+	keyring := NewKeyring()
+
+	lines := strings.FieldsFunc(str, func(r rune) bool {
+		return r == '\n' || r == ','
+	})
+
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		id, encoded, found := strings.Cut(line, ":")
+
+		if !found {
+			id = DEFAULT_KEY_ID
+			encoded = line
+		}
+
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+
+		if err != nil {
+			return nil, errors.New("invalid key " + id + ": " + err.Error())
+		}
+
+		err = keyring.Add(strings.TrimSpace(id), key)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(keyring.order) == 0 {
+		return nil, errors.New("no keys found")
+	}
+
+	return keyring, nil
+}
+
+// Load keys from file (see ParseKeys())
+func LoadKeyFile(path string) (*Keyring, error) {
+	data, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseKeys(string(data))
+}
+
+// Load keys from environment variable (see ParseKeys())
+func LoadKeyEnv(name string) (*Keyring, error) {
+	value, ok := os.LookupEnv(name)
+
+	if !ok {
+		return nil, errors.New("environment variable " + name + " not set")
+	}
+
+	return ParseKeys(value)
+}
+
+// Generate random base64 encoded AES-256 key
+func GenerateKey() (string, error) {
+	key := make([]byte, 32)
+
+	_, err := rand.Read(key)
+
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// Add AES key (16, 24 or 32 bytes) to Keyring, the first key added becomes primary
+func (keyring *Keyring) Add(id string, key []byte) error {
+	if id == "" || strings.Contains(id, ":") {
+		return errors.New("invalid key id " + id)
+	}
+
+	block, err := aes.NewCipher(key)
+
+	if err != nil {
+		return err
+	}
+
+	aead, err := cipher.NewGCM(block)
+
+	if err != nil {
+		return err
+	}
+
+	_, exists := keyring.keys[id]
+
+	if !exists {
+		keyring.order = append(keyring.order, id)
+	}
+
+	keyring.keys[id] = aead
+
+	if keyring.Primary == "" {
+		keyring.Primary = id
+	}
+
+	return nil
+}
+
+// Encrypt value with primary key into `enc:v1:<keyid>:<base64>`
+func (keyring *Keyring) Encrypt(value string) (string, error) {
+	aead, ok := keyring.keys[keyring.Primary]
+
+	if !ok {
+		return "", errors.New("primary key " + keyring.Primary + " not found")
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+
+	_, err := rand.Read(nonce)
+
+	if err != nil {
+		return "", err
+	}
+
+	sealed := aead.Seal(nonce, nonce, []byte(value), nil)
+
+	return ENCRYPTED_PREFIX + keyring.Primary + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt `enc:v1:<keyid>:<base64>` or `enc:v1:<base64>` (tries every key)
+func (keyring *Keyring) Decrypt(value string) (string, error) {
+	payload, ok := strings.CutPrefix(value, ENCRYPTED_PREFIX)
+
+	if !ok {
+		return "", errors.New("value is not encrypted")
+	}
+
+	ids := keyring.order
+
+	id, encoded, found := strings.Cut(payload, ":")
+
+	if found {
+		ids = []string{id}
+	} else {
+		encoded = payload
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+
+	if err != nil {
+		return "", err
+	}
+
+	for _, id := range ids {
+		aead, ok := keyring.keys[id]
+
+		if !ok {
+			return "", errors.New("key " + id + " not found")
+		}
+
+		if len(sealed) < aead.NonceSize() {
+			return "", errors.New("encrypted value too short")
+		}
+
+		nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+
+		plain, err := aead.Open(nil, nonce, ciphertext, nil)
+
+		if err == nil {
+			return string(plain), nil
+		}
+	}
+
+	return "", errors.New("could not decrypt value")
+}
+
+// Re-encrypt value with primary key, used for key rotation
+func (keyring *Keyring) Rotate(value string) (string, error) {
+	plain, err := keyring.Decrypt(value)
+
+	if err != nil {
+		return "", err
+	}
+
+	return keyring.Encrypt(plain)
+}
+
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, ENCRYPTED_PREFIX)
+}
+
+// Recursively decrypts encrypted strings in data, calls onSecret with the path of every decrypted value
+func DecryptData(key string, data any, keyring *Keyring, onSecret func(path string)) (any, error) {
+	switch asserted := data.(type) {
+	case map[string]any:
+		for mapKey, mapValue := range asserted {
+			newKey := mapKey
+
+			if key != "" {
+				newKey = joinPaths(key, mapKey)
+			}
+
+			decrypted, err := DecryptData(newKey, mapValue, keyring, onSecret)
+
+			if err != nil {
+				return data, err
+			}
+
+			asserted[mapKey] = decrypted
+		}
+
+		return asserted, nil
+
+	case []any:
+		for i, arrayValue := range asserted {
+			newKey := joinPaths(key, strconv.Itoa(i))
+
+			if key == "" {
+				newKey = strconv.Itoa(i)
+			}
+
+			decrypted, err := DecryptData(newKey, arrayValue, keyring, onSecret)
+
+			if err != nil {
+				return data, err
+			}
+
+			asserted[i] = decrypted
+		}
+
+		return asserted, nil
+
+	case string:
+		if !IsEncrypted(asserted) {
+			return asserted, nil
+		}
+
+		plain, err := keyring.Decrypt(asserted)
+
+		if err != nil {
+			return asserted, errors.New("could not decrypt " + key + ": " + err.Error())
+		}
+
+		if onSecret != nil {
+			onSecret(key)
+		}
+
+		return plain, nil
+
+	default:
+		return asserted, nil
+	}
+}
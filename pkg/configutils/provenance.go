@@ -0,0 +1,216 @@
+package configutils
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	SOURCE_DATA = "data"
+	SOURCE_ENV = "env"
+)
+
+type FieldInfo struct {
+	Path			string
+	Field			reflect.StructField
+	Provenance		string
+}
+
+type FieldError struct {
+	Field		FieldInfo
+	Message		string
+}
+
+func (err *FieldError) Error() string {
+	if err.Field.Provenance != "" {
+		return err.Field.Path + " (from " + err.Field.Provenance + "): " + err.Message
+	}
+
+	return err.Field.Path + ": " + err.Message
+}
+
+// Create error attributed to field (path + provenance)
+func NewFieldError(field FieldInfo, message string) *FieldError {
+	return &FieldError{
+		Field: field,
+		Message: message,
+	}
+}
+
+// Get source of path (`file:<path>`, `dotenv:<path>`, `dir:<path>`, `env` or `data`)
+func (config *Config) Provenance(path string) string {
+	config.mutex.RLock()
+	defer config.mutex.RUnlock()
+
+	return config.provenanceLocked(path)
+}
+
+func (config *Config) provenanceLocked(path string) string {
+	path = strings.ToLower(path)
+
+	source, ok := config.provenance[path]
+
+	if ok {
+		return source
+	}
+
+	// containers get the source of their first child
+	keys := make([]string, 0)
+
+	for key := range config.provenance {
+		if strings.HasPrefix(key, path + DELIM) {
+			keys = append(keys, key)
+		}
+	}
+
+	if len(keys) > 0 {
+		sort.Strings(keys)
+
+		return config.provenance[keys[0]]
+	}
+
+	// values inside of arrays get the source of their parent
+	parts := splitPath(path)
+
+	for i := len(parts) - 1; i > 0; i-- {
+		source, ok := config.provenance[joinPaths(parts[:i]...)]
+
+		if ok {
+			return source
+		}
+	}
+
+	return ""
+}
+
+// Expects config.mutex to be held
+func (config *Config) recordProvenance(data map[string]any, source string) {
+	if config.provenance == nil {
+		config.provenance = map[string]string{}
+	}
+
+	flat := map[string]any{}
+
+	Flatten("", data, flat)
+
+	for key := range flat {
+		config.provenance[strings.ToLower(key)] = source
+	}
+}
+
+// Resolve field pointer (`&cfg.Server.TLS.Cert`) to full koanf path and struct field of said field,
+// works through pointers, embedded structs, arrays and Opt[T]
+func ResolveFieldPointer(schema any, fieldPointer any) (FieldInfo, bool) {
+	v := reflect.ValueOf(schema)
+
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return FieldInfo{}, false
+		}
+
+		v = v.Elem()
+	}
+
+	if !v.CanAddr() {
+		return FieldInfo{}, false
+	}
+
+	target := reflect.ValueOf(fieldPointer)
+
+	if target.Kind() != reflect.Pointer || target.IsNil() {
+		return FieldInfo{}, false
+	}
+
+	return findField(v, target.Pointer(), target.Type().Elem(), nil, reflect.StructField{})
+}
+
+// Resolve field pointer including provenance of its value (see ResolveFieldPointer())
+func (config *Config) ResolveField(schema any, fieldPointer any) (FieldInfo, bool) {
+	info, ok := ResolveFieldPointer(schema, fieldPointer)
+
+	if ok {
+		info.Provenance = config.Provenance(info.Path)
+	}
+
+	return info, ok
+}
+
+// Create error attributed to field pointer (see NewFieldError())
+func (config *Config) FieldError(schema any, fieldPointer any, message string) error {
+	info, _ := config.ResolveField(schema, fieldPointer)
+
+	return NewFieldError(info, message)
+}
+
+func findField(value reflect.Value, target uintptr, targetType reflect.Type, path []string, owner reflect.StructField) (FieldInfo, bool) {
+	switch value.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if value.IsNil() {
+			return FieldInfo{}, false
+		}
+
+		return findField(value.Elem(), target, targetType, path, owner)
+
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			elem := value.Index(i)
+			elemPath := append(append([]string{}, path...), strconv.Itoa(i))
+
+			if elem.CanAddr() && elem.Addr().Pointer() == target && elem.Type() == targetType {
+				return FieldInfo{
+					Path: joinPaths(elemPath...),
+					Field: owner,
+				}, true
+			}
+
+			info, ok := findField(elem, target, targetType, elemPath, owner)
+
+			if ok {
+				return info, true
+			}
+		}
+
+	case reflect.Struct:
+		for i := 0; i < value.NumField(); i++ {
+			field := value.Type().Field(i)
+
+			if !field.IsExported() && !field.Anonymous {
+				continue
+			}
+
+			fieldValue := value.Field(i)
+
+			fieldPath := path
+			fieldOwner := owner
+
+			key, _, _ := strings.Cut(field.Tag.Get("koanf"), ",")
+
+			if key != "" {
+				fieldPath = append(append([]string{}, path...), key)
+				fieldOwner = field
+			}
+
+			if fieldValue.Addr().Pointer() == target && field.Type == targetType {
+				// untagged fields (for example Opt[T].Value) belong to their owner
+				if key == "" && owner.Name != "" {
+					field = owner
+				}
+
+				return FieldInfo{
+					Path: joinPaths(fieldPath...),
+					Field: field,
+				}, true
+			}
+
+			info, ok := findField(fieldValue, target, targetType, fieldPath, fieldOwner)
+
+			if ok {
+				return info, true
+			}
+		}
+	}
+
+	return FieldInfo{}, false
+}
@@ -0,0 +1,293 @@
+package configutils
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	t "github.com/codeshelldev/gotl/pkg/configutils/types"
+)
+
+type ReferenceEntry struct {
+	Path				string
+	Type				string
+	Default				any
+	Aliases				[]string
+	Env					string
+	Description			string
+	Optional			bool
+	Deprecated			bool
+	DeprecationNote		string
+}
+
+type Reference []ReferenceEntry
+
+var optionalType = reflect.TypeFor[t.Optional]()
+
+// Build config reference from struct schema (uses `koanf`, `aliases`, `default`, `description` and `deprecated` tags)
+func BuildReference(id string, schema any) Reference {
+	out := Reference{}
+
+	v := reflect.ValueOf(schema)
+
+	if v.IsValid() {
+		getReference(id, v.Type(), v, "", &out)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Path < out[j].Path
+	})
+
+	return out
+}
+
+func getReference(id string, schema reflect.Type, value reflect.Value, parent string, out *Reference) {
+	for schema.Kind() == reflect.Pointer {
+		schema = schema.Elem()
+
+		if value.IsValid() {
+			if value.IsNil() {
+				value = reflect.Value{}
+			} else {
+				value = value.Elem()
+			}
+		}
+	}
+
+	if schema.Kind() != reflect.Struct {
+		return
+	}
+
+	for field := range schema.Fields() {
+		var fieldValue reflect.Value
+		if value.IsValid() {
+			fieldValue = value.FieldByIndex(field.Index)
+		}
+
+		base := field.Tag.Get("koanf")
+
+		if base == "" {
+			// embedded structs share their parent's path
+			if field.Anonymous {
+				getReference(id, field.Type, fieldValue, parent, out)
+			}
+
+			continue
+		}
+
+		path := strings.ToLower(base)
+		if parent != "" {
+			path = joinPaths(parent, path)
+		}
+
+		fieldType := field.Type
+		optional := false
+
+		if fieldType.Implements(optionalType) {
+			optional = true
+
+			fieldType = reflect.Zero(fieldType).Interface().(t.Optional).OptionalType()
+			fieldValue = getOptionalValue(fieldValue)
+		}
+
+		elemType := fieldType
+		for elemType.Kind() == reflect.Pointer {
+			elemType = elemType.Elem()
+		}
+
+		// structs are only namespaces, document their fields instead
+		if elemType.Kind() == reflect.Struct {
+			getReference(id, fieldType, fieldValue, path, out)
+			continue
+		}
+
+		_, deprecated := field.Tag.Lookup("deprecated")
+
+		*out = append(*out, ReferenceEntry{
+			Path:				path,
+			Type:				fieldType.String(),
+			Default:			getDefault(id, field, fieldValue),
+			Aliases:			getAliases(id, field, parent),
+			Env:				EnvName(path),
+			Description:		getFieldWithID(id, "description", field.Tag),
+			Optional:			optional,
+			Deprecated:			deprecated,
+			DeprecationNote:	getFieldWithID(id, "deprecated", field.Tag),
+		})
+
+		switch elemType.Kind() {
+		case reflect.Slice, reflect.Array:
+			getReference(id, elemType.Elem(), reflect.Value{}, joinPaths(path, "*"), out)
+
+		case reflect.Map:
+			if elemType.Key().Kind() == reflect.String {
+				getReference(id, elemType.Elem(), reflect.Value{}, joinPaths(path, "*"), out)
+			}
+		}
+	}
+}
+
+func getOptionalValue(value reflect.Value) reflect.Value {
+	if !value.IsValid() {
+		return value
+	}
+
+	if !value.FieldByName("Set").Bool() {
+		return reflect.Value{}
+	}
+
+	return value.FieldByName("Value")
+}
+
+func getDefault(id string, field reflect.StructField, value reflect.Value) any {
+	if id != "" {
+		def, ok := field.Tag.Lookup(id + ">default")
+
+		if ok {
+			return def
+		}
+	}
+
+	def, ok := field.Tag.Lookup("default")
+
+	if ok {
+		return def
+	}
+
+	if !value.IsValid() || value.IsZero() {
+		return nil
+	}
+
+	return getValueSafe(value)
+}
+
+func getAliases(id string, field reflect.StructField, parent string) []string {
+	aliasesRaw := getFieldWithID(id, "aliases", field.Tag)
+
+	if aliasesRaw == "" {
+		return nil
+	}
+
+	aliases := []string{}
+
+	for alias := range strings.SplitSeq(aliasesRaw, ",") {
+		alias = strings.ToLower(strings.TrimSpace(alias))
+
+		if alias == "" {
+			continue
+		}
+
+		if strings.HasPrefix(alias, ".") {
+			alias = alias[1:]
+		} else if parent != "" {
+			alias = joinPaths(parent, alias)
+		}
+
+		aliases = append(aliases, alias)
+	}
+
+	return aliases
+}
+
+// Derive environment variable name from config path (`server.port` => `SERVER_PORT`)
+func EnvName(path string) string {
+	return strings.ToUpper(strings.ReplaceAll(path, DELIM, "_"))
+}
+
+// Render reference as Markdown table
+func (reference Reference) Markdown() string {
+	var builder strings.Builder
+
+	builder.WriteString("| Key | Type | Default | Env | Aliases | Description |\n")
+	builder.WriteString("| --- | --- | --- | --- | --- | --- |\n")
+
+	for _, entry := range reference {
+		key := "`" + entry.Path + "`"
+
+		if entry.Optional {
+			key += " _(optional)_"
+		}
+
+		description := entry.Description
+
+		if entry.Deprecated {
+			description = strings.TrimSpace("**Deprecated** " + entry.DeprecationNote + " " + description)
+		}
+
+		fmt.Fprintf(&builder, "| %s | `%s` | %s | `%s` | %s | %s |\n",
+			key,
+			escapeMarkdown(entry.Type),
+			markdownCode(formatDefault(entry.Default)),
+			entry.Env,
+			markdownCode(strings.Join(entry.Aliases, "`, `")),
+			escapeMarkdown(description),
+		)
+	}
+
+	return builder.String()
+}
+
+// Render reference as plain text (for `--help-config` and similar)
+func (reference Reference) Text() string {
+	var builder strings.Builder
+
+	writer := tabwriter.NewWriter(&builder, 0, 4, 2, ' ', 0)
+
+	for i, entry := range reference {
+		if i > 0 {
+			fmt.Fprintln(writer)
+		}
+
+		header := entry.Path + " (" + entry.Type
+
+		if entry.Optional {
+			header += ", optional"
+		}
+
+		fmt.Fprintln(writer, header + ")")
+
+		if entry.Description != "" {
+			fmt.Fprintln(writer, "    " + entry.Description)
+		}
+
+		if entry.Default != nil {
+			fmt.Fprintf(writer, "    default:\t%s\n", formatDefault(entry.Default))
+		}
+
+		fmt.Fprintf(writer, "    env:\t%s\n", entry.Env)
+
+		if len(entry.Aliases) > 0 {
+			fmt.Fprintf(writer, "    aliases:\t%s\n", strings.Join(entry.Aliases, ", "))
+		}
+
+		if entry.Deprecated {
+			fmt.Fprintf(writer, "    deprecated:\t%s\n", entry.DeprecationNote)
+		}
+	}
+
+	writer.Flush()
+
+	return builder.String()
+}
+
+func formatDefault(value any) string {
+	if value == nil {
+		return ""
+	}
+
+	return fmt.Sprint(value)
+}
+
+func markdownCode(str string) string {
+	if str == "" {
+		return ""
+	}
+
+	return "`" + str + "`"
+}
+
+func escapeMarkdown(str string) string {
+	return strings.ReplaceAll(str, "|", "\\|")
+}
@@ -0,0 +1,185 @@
+package configutils
+
+import (
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/codeshelldev/gotl/pkg/templating"
+)
+
+type ReferenceCycleError struct {
+	Chain	[]string
+}
+
+func (err *ReferenceCycleError) Error() string {
+	return "config reference cycle: " + strings.Join(err.Chain, " -> ")
+}
+
+// Get config keys referenced (`${{ .config.key }}`) by templates in value
+func GetReferences(value any) []string {
+	refs := []string{}
+
+	switch asserted := value.(type) {
+	case map[string]any:
+		for _, mapValue := range asserted {
+			refs = append(refs, GetReferences(mapValue)...)
+		}
+
+	case []any:
+		for _, arrayValue := range asserted {
+			refs = append(refs, GetReferences(arrayValue)...)
+		}
+
+	case string:
+		if !strings.Contains(asserted, TEMPLATE_LEFT_DELIM) {
+			return refs
+		}
+
+		tree := parse.New("")
+		tree.Mode = parse.SkipFuncCheck
+
+		_, err := tree.Parse(asserted, TEMPLATE_LEFT_DELIM, TEMPLATE_RIGHT_DELIM, map[string]*parse.Tree{})
+
+		if err != nil {
+			// templating will report the error
+			return refs
+		}
+
+		templt, err := template.New("").AddParseTree("", tree)
+
+		if err != nil {
+			return refs
+		}
+
+		templating.WalkTemplate(templt, func(node parse.Node) bool {
+			var ident []string
+
+			switch asserted := node.(type) {
+			case *parse.FieldNode:
+				ident = asserted.Ident
+			case *parse.VariableNode:
+				// $.config.key
+				if len(asserted.Ident) > 0 && asserted.Ident[0] == "$" {
+					ident = asserted.Ident[1:]
+				}
+			}
+
+			if len(ident) > 1 && ident[0] == "config" {
+				refs = append(refs, joinPaths(ident[1:]...))
+			}
+
+			return false
+		})
+	}
+
+	return refs
+}
+
+// Sorts flat keys, so that every key comes after the keys it references
+func resolveReferenceOrder(flat map[string]any) ([]string, error) {
+	keys := make([]string, 0, len(flat))
+
+	for key := range flat {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	dependencies := map[string][]string{}
+
+	for _, key := range keys {
+		for _, ref := range GetReferences(flat[key]) {
+			for _, other := range keys {
+				// reference to parent, child or key itself
+				if other == ref || strings.HasPrefix(other, ref + DELIM) || strings.HasPrefix(ref, other + DELIM) {
+					dependencies[key] = append(dependencies[key], other)
+				}
+			}
+		}
+	}
+
+	order := make([]string, 0, len(keys))
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	state := map[string]int{}
+	stack := []string{}
+
+	var visit func(key string) error
+
+	visit = func(key string) error {
+		switch state[key] {
+		case visited:
+			return nil
+		case visiting:
+			start := 0
+
+			for i, stackKey := range stack {
+				if stackKey == key {
+					start = i
+					break
+				}
+			}
+
+			chain := append([]string{}, stack[start:]...)
+
+			return &ReferenceCycleError{
+				Chain: append(chain, key),
+			}
+		}
+
+		state[key] = visiting
+		stack = append(stack, key)
+
+		for _, dependency := range dependencies[key] {
+			err := visit(dependency)
+
+			if err != nil {
+				return err
+			}
+		}
+
+		stack = stack[:len(stack) - 1]
+		state[key] = visited
+
+		order = append(order, key)
+
+		return nil
+	}
+
+	for _, key := range keys {
+		err := visit(key)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return order, nil
+}
+
+func setByPath(root map[string]any, parts []string, value any) {
+	current := root
+
+	for i, part := range parts {
+		if i == len(parts) - 1 {
+			current[part] = value
+			return
+		}
+
+		next, ok := current[part].(map[string]any)
+
+		if !ok {
+			next = map[string]any{}
+			current[part] = next
+		}
+
+		current = next
+	}
+}
@@ -0,0 +1,172 @@
+package configutils
+
+import (
+	"fmt"
+
+	"github.com/knadh/koanf/v2"
+)
+
+// Step of a transactional reload, operates on the staged Config
+type ReloadStep func(staged *Config) error
+
+type ReloadError struct {
+	Step	int
+	Err		error
+}
+
+func (err *ReloadError) Error() string {
+	return fmt.Sprintf("reload step %d failed: %s", err.Step, err.Err.Error())
+}
+
+func (err *ReloadError) Unwrap() error {
+	return err.Err
+}
+
+// Create empty Config with the same delimiter and reload funcs, used for building layers off to the side
+func (config *Config) Stage() *Config {
+	reloadLock := config.getReloadLock()
+
+	config.mutex.RLock()
+	defer config.mutex.RUnlock()
+
+	staged := NewWith(config.Layer.Delim(), config.ReloadFunc)
+
+	staged.ReloadErrorFunc = config.ReloadErrorFunc
+	staged.Keyring = config.Keyring
+
+	// watchers of staged Configs are serialized with ours
+	staged.reloadLock = reloadLock
+
+	return staged
+}
+
+// Swap layer of staged Config into Config,
+// files watched by the staged Config replace the previous watchers of the same files
+func (config *Config) Commit(staged *Config) {
+	staged.mutex.Lock()
+	defer staged.mutex.Unlock()
+
+	config.mutex.Lock()
+	defer config.mutex.Unlock()
+
+	config.Layer = staged.Layer
+	config.secrets = staged.secrets
+	config.provenance = staged.provenance
+
+	for path, w := range staged.watchers {
+		config.replaceWatcherLocked(path, w)
+	}
+
+	staged.watchers = nil
+}
+
+// Build new layers with steps and swap them in if every step succeeds,
+// otherwise the previous config is kept and the error is passed to ReloadErrorFunc
+func (config *Config) Reload(steps ...ReloadStep) error {
+	staged, err := config.stageWith(steps...)
+
+	if err != nil {
+		config.reportReloadError(err)
+
+		return err
+	}
+
+	config.Commit(staged)
+
+	return nil
+}
+
+// Reload Config transactionally and unmarshal path into out,
+// validate is called with the new schema before anything is swapped in
+func ReloadInto[T any](config *Config, path string, out *T, validate func(*T) error, steps ...ReloadStep) error {
+	var schema T
+
+	steps = append(steps, UnmarshalStep(path, &schema), func(staged *Config) error {
+		if validate == nil {
+			return nil
+		}
+
+		return validate(&schema)
+	})
+
+	staged, err := config.stageWith(steps...)
+
+	if err != nil {
+		config.reportReloadError(err)
+
+		return err
+	}
+
+	config.Commit(staged)
+
+	*out = schema
+
+	return nil
+}
+
+func (config *Config) stageWith(steps ...ReloadStep) (*Config, error) {
+	staged := config.Stage()
+
+	for i, step := range steps {
+		err := step(staged)
+
+		if err != nil {
+			// the previous watchers keep running, so that fixes get picked up
+			staged.unwatchAll()
+
+			return nil, &ReloadError{
+				Step: i,
+				Err: err,
+			}
+		}
+	}
+
+	return staged, nil
+}
+
+func (config *Config) reportReloadError(err error) {
+	config.mutex.RLock()
+	reloadErrorFunc := config.ReloadErrorFunc
+	config.mutex.RUnlock()
+
+	if reloadErrorFunc != nil {
+		reloadErrorFunc(err)
+	}
+}
+
+// Step for loading file with parser into staged Config
+func FileStep(path string, parser koanf.Parser) ReloadStep {
+	return func(staged *Config) error {
+		_, err := staged.LoadFile(path, parser)
+
+		return err
+	}
+}
+
+// Step for loading data into staged Config path
+func DataStep(data any, path string) ReloadStep {
+	return func(staged *Config) error {
+		return staged.Load(data, path)
+	}
+}
+
+// Step for templating staged Config with environment + variables
+func TemplateStep(variables map[string]any) ReloadStep {
+	return func(staged *Config) error {
+		return staged.TemplateConfig(variables)
+	}
+}
+
+// Step for applying transform funcs to staged Config
+func TransformStep(id string, schema any, path string, options TransformOptions) ReloadStep {
+	return func(staged *Config) error {
+		return staged.ApplyTransformFuncs(id, schema, path, options)
+	}
+}
+
+// Step for unmarshalling staged Config into schema
+func UnmarshalStep(path string, schema any) ReloadStep {
+	return func(staged *Config) error {
+		return staged.Unmarshal(path, schema)
+	}
+}
@@ -0,0 +1,125 @@
+package configutils
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/knadh/koanf/v2"
+)
+
+const REDACTED = "[REDACTED]"
+
+type rawProvider map[string]any
+
+func (provider rawProvider) ReadBytes() ([]byte, error) {
+	return nil, errors.New("raw provider does not support this method")
+}
+
+func (provider rawProvider) Read() (map[string]any, error) {
+	return provider, nil
+}
+
+func readProvider(provider koanf.Provider, parser koanf.Parser) (map[string]any, error) {
+	if parser == nil {
+		return provider.Read()
+	}
+
+	bytes, err := provider.ReadBytes()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return parser.Unmarshal(bytes)
+}
+
+// Mark path as secret, secret values (and their children) are redacted in dumps
+func (config *Config) MarkSecret(path string) {
+	config.mutex.Lock()
+	defer config.mutex.Unlock()
+
+	config.markSecretLocked(path)
+}
+
+func (config *Config) markSecretLocked(path string) {
+	if config.secrets == nil {
+		config.secrets = map[string]bool{}
+	}
+
+	config.secrets[path] = true
+}
+
+// Paths marked as secret
+func (config *Config) secretPaths() []string {
+	config.mutex.RLock()
+	defer config.mutex.RUnlock()
+
+	paths := make([]string, 0, len(config.secrets))
+
+	for path := range config.secrets {
+		paths = append(paths, path)
+	}
+
+	return paths
+}
+
+// Check if path (or one of its parents) is secret
+func (config *Config) IsSecret(path string) bool {
+	config.mutex.RLock()
+	defer config.mutex.RUnlock()
+
+	return config.isSecretLocked(path)
+}
+
+func (config *Config) isSecretLocked(path string) bool {
+	parts := splitPath(path)
+
+	for i := range parts {
+		if config.secrets[joinPaths(parts[:i + 1]...)] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Flattened config with secret values redacted
+func (config *Config) Redacted() map[string]any {
+	config.mutex.RLock()
+	defer config.mutex.RUnlock()
+
+	flat := map[string]any{}
+
+	Flatten("", config.Layer.Raw(), flat)
+
+	for key := range flat {
+		if config.isSecretLocked(key) {
+			flat[key] = REDACTED
+		}
+	}
+
+	return flat
+}
+
+// Returns a `key -> value` representation of the config with secret values redacted
+func (config *Config) Sprint() string {
+	flat := config.Redacted()
+
+	keys := make([]string, 0, len(flat))
+
+	for key := range flat {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	var builder strings.Builder
+
+	for _, key := range keys {
+		fmt.Fprintf(&builder, "%s -> %v\n", key, flat[key])
+	}
+
+	return builder.String()
+}
@@ -1,9 +1,12 @@
 package configutils
 
 import (
+	"errors"
 	"reflect"
 	"strconv"
 	"strings"
+
+	"github.com/knadh/koanf/v2"
 )
 
 type TransformTarget struct {
@@ -138,27 +141,96 @@ func Unflatten(flat map[string]any) map[string]any {
 	return root
 }
 
+// Attempts of ApplyTransformFuncs before giving up on a Config that keeps changing
+const transformAttempts = 3
+
 // Apply Transform funcs based on `transform`, `childtransform` and `aliases` in struct schema
-func (config Config) ApplyTransformFuncs(id string, schema any, path string, options TransformOptions) {
-	raw := config.Layer.Get(path)
+// Transform and OnUse funcs are called without holding the lock (they may read the Config),
+// if the Config changes meanwhile the transform is applied again to the new layer (OnUse funcs only run on the first attempt)
+func (config *Config) ApplyTransformFuncs(id string, schema any, path string, options TransformOptions) error {
+	targets := BuildTransformMap(id, schema)
 
-	flat := map[string]any{}
-	Flatten("", raw, flat)
+	for range transformAttempts {
+		layer := config.Snapshot()
 
-	targets := BuildTransformMap(id, schema)
+		flat := map[string]any{}
+		Flatten("", layer.Get(path), flat)
+
+		transformed, renamed := applyTransforms(flat, targets, options)
+
+		result := Unflatten(transformed)
+
+		res, err := wrapData(result, path)
+
+		if err != nil {
+			return err
+		}
 
-	transformed := ApplyTransforms(flat, targets, options)
+		// build the transformed layer off to the side, readers never see an empty layer
+		next := koanf.New(layer.Delim())
 
-	result := Unflatten(transformed)
+		err = next.Load(rawProvider(res), nil)
+
+		if err != nil {
+			return err
+		}
+
+		config.mutex.Lock()
+
+		if config.Layer == layer {
+			config.Layer = next
+			config.renameLocked(path, renamed)
+			config.mutex.Unlock()
+
+			return nil
+		}
+
+		config.mutex.Unlock()
+
+		options.OnUse = nil
+	}
 
-	config.Layer.Delete("")
-	config.Load(result, path)
+	return errors.New("config changed while applying transforms, gave up after " + strconv.Itoa(transformAttempts) + " attempts")
+}
+
+// Secrets and provenance follow renamed keys, so that transformed values keep being redacted.
+// Expects config.mutex to be held
+func (config *Config) renameLocked(path string, renamed map[string]string) {
+	for key, outputKey := range renamed {
+		if path != "" {
+			key = joinPaths(path, key)
+			outputKey = joinPaths(path, outputKey)
+		}
+
+		if key == outputKey {
+			continue
+		}
+
+		if config.isSecretLocked(key) {
+			config.markSecretLocked(outputKey)
+		}
+
+		source := config.provenanceLocked(key)
+
+		if source != "" {
+			config.provenance[strings.ToLower(outputKey)] = source
+		}
+	}
 }
 
 func ApplyTransforms(flat map[string]any, targets map[string]TransformTarget, options TransformOptions) map[string]any {
+	out, _ := applyTransforms(flat, targets, options)
+
+	return out
+}
+
+// Apply transforms and return the output key of every flattened key
+func applyTransforms(flat map[string]any, targets map[string]TransformTarget, options TransformOptions) (map[string]any, map[string]string) {
 	out := map[string]any{}
+	renamed := map[string]string{}
 
 	for key, val := range flat {
+		sourceKey := key
 		keyParts := splitPath(key)
 
 		newKeyParts := []string{}
@@ -248,10 +320,13 @@ func ApplyTransforms(flat map[string]any, targets map[string]TransformTarget, op
 			newKeyParts = append(newKeyParts, outputBase)
 		}
 
-		out[joinPaths(newKeyParts...)] = newValue
+		outputKey := joinPaths(newKeyParts...)
+
+		out[outputKey] = newValue
+		renamed[sourceKey] = outputKey
 	}
 
-	return out
+	return out, renamed
 }
 
 func resolveTransform(lower string, targets map[string]TransformTarget) (string, TransformTarget) {
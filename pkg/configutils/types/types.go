@@ -1,6 +1,8 @@
 package configutils
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"reflect"
 
@@ -21,6 +23,16 @@ type Opt[T any] struct {
 	Value	*T
 }
 
+// Implemented by Opt[T], used for detecting optional fields in schemas
+type Optional interface {
+	OptionalType() reflect.Type
+}
+
+// Returns the wrapped type T
+func (optional Opt[T]) OptionalType() reflect.Type {
+	return reflect.TypeFor[T]()
+}
+
 // Returns optional.Value (if set) or fallback
 func (optional Opt[T]) ValueOrFallback(fallback T) T {
     if optional.Set {
@@ -35,54 +47,161 @@ func (optional Opt[T]) ValueOrFallback(fallback T) T {
     return fallback
 }
 
-// Returns optional.Value (if set) or fallback.Value
+// Returns optional.Value (if set) or fallback.Value (if it has a value), explicit null in optional returns T empty
 func (optional Opt[T]) OptOrFallback(fallback Opt[T]) T {
     if optional.Set {
-        if optional.Value != nil {
-            return *optional.Value
-        }
+        return optional.valueOrZero()
+    }
 
-        var zero T
-        return zero
+    return fallback.valueOrZero()
+}
+
+// Returns optional.Value (if set) or fallback.Value (if set), else T empty is returned
+//
+// Deprecated: same as OptOrFallback, use OptOrFallback instead
+func (optional Opt[T]) OptOrEmpty(fallback Opt[T]) T {
+    return optional.OptOrFallback(fallback)
+}
+
+// Create Opt with value
+func Some[T any](value T) Opt[T] {
+    return Opt[T]{
+        Set: true,
+        Value: &value,
     }
+}
 
-    if fallback.Value != nil {
-        if fallback.Value != nil {
-            return *fallback.Value
-        }
+// Create Opt with explicit null
+func Null[T any]() Opt[T] {
+    return Opt[T]{
+        Set: true,
+    }
+}
 
-        var zero T
-        return zero
+// Returns true if optional was set to explicit null
+func (optional Opt[T]) IsNull() bool {
+    return optional.Set && optional.Value == nil
+}
+
+// Returns true if optional was set to a value
+func (optional Opt[T]) HasValue() bool {
+    return optional.Set && optional.Value != nil
+}
+
+// Returns true if optional is absent, used by `omitzero` (encoding/json, Go 1.24+) and `omitempty` of yaml encoders
+func (optional Opt[T]) IsZero() bool {
+    return !optional.Set
+}
+
+// Returns optional.Value and whether optional has a value
+func (optional Opt[T]) Get() (T, bool) {
+    return optional.valueOrZero(), optional.HasValue()
+}
+
+// Returns optional if it has a value, else other (absent and null fall through)
+func (optional Opt[T]) Or(other Opt[T]) Opt[T] {
+    if optional.HasValue() {
+        return optional
     }
 
-    var zero T
-    return zero
+    return other
 }
 
-// Returns optional.Value (if set) or fallback.Value (if set), else T empty is returned
-func (optional Opt[T]) OptOrEmpty(fallback Opt[T]) T {
-    if optional.Set {
-        if optional.Value != nil {
-            return *optional.Value
-        }
+// Layer override on top of optional: absent keeps optional, null and values replace it
+func (optional Opt[T]) Merge(override Opt[T]) Opt[T] {
+    if override.Set {
+        return override
+    }
 
-        var zero T
-        return zero
+    return optional
+}
+
+// Merge layers in order, later layers win (see Opt.Merge())
+func MergeOpts[T any](layers ...Opt[T]) Opt[T] {
+    var merged Opt[T]
+
+    for _, layer := range layers {
+        merged = merged.Merge(layer)
     }
 
-    if fallback.Set {
-        if fallback.Value != nil {
-            return *fallback.Value
+    return merged
+}
+
+// Apply fn to value of optional, absent and null are kept
+func Map[T any, U any](optional Opt[T], fn func(T) U) Opt[U] {
+    if !optional.HasValue() {
+        return Opt[U]{
+            Set: optional.Set,
         }
+    }
 
-        var zero T
-        return zero
+    return Some(fn(*optional.Value))
+}
+
+func (optional Opt[T]) valueOrZero() T {
+    if optional.Value != nil {
+        return *optional.Value
     }
 
     var zero T
     return zero
 }
 
+// Absent and null are marshalled as `null`, use `omitzero` to omit absent values
+func (optional Opt[T]) MarshalJSON() ([]byte, error) {
+    if !optional.HasValue() {
+        return []byte("null"), nil
+    }
+
+    return json.Marshal(*optional.Value)
+}
+
+// Only called for present keys: `null` sets explicit null
+func (optional *Opt[T]) UnmarshalJSON(data []byte) error {
+    optional.Set = true
+    optional.Value = nil
+
+    if string(bytes.TrimSpace(data)) == "null" {
+        return nil
+    }
+
+    var value T
+
+    err := json.Unmarshal(data, &value)
+    if err != nil {
+        return err
+    }
+
+    optional.Value = &value
+
+    return nil
+}
+
+// Implements yaml Marshaler, absent and null are marshalled as `null`
+func (optional Opt[T]) MarshalYAML() (any, error) {
+    if !optional.HasValue() {
+        return nil, nil
+    }
+
+    return *optional.Value, nil
+}
+
+// Implements (obsolete) yaml Unmarshaler, yaml decoders don't call unmarshalers for `null`,
+// so explicit null decodes as absent
+func (optional *Opt[T]) UnmarshalYAML(unmarshal func(any) error) error {
+    var value *T
+
+    err := unmarshal(&value)
+    if err != nil {
+        return err
+    }
+
+    optional.Set = true
+    optional.Value = value
+
+    return nil
+}
+
 func (optional *Opt[T]) UnmarshalMapstructure(raw any) error {
     optional.Set = true
 
@@ -1,26 +1,75 @@
 package docker
 
 import (
+	"context"
+	"errors"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
+	"time"
 )
 
 var stop chan os.Signal
 
+// closed once the first signal was received
+var signaled chan struct{}
+
 // Run main func with signal notification
 func Run(main func()) chan os.Signal {
-	stop = make(chan os.Signal, 1)
-	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+	forward := make(chan os.Signal, 1)
+	received := make(chan struct{})
+
+	stop = forward
+	signaled = received
+
+	go func() {
+		var once sync.Once
+
+		for sig := range signals {
+			once.Do(func() { close(received) })
+
+			// don't block if the caller doesn't read the returned channel
+			select {
+			case forward <- sig:
+			default:
+			}
+		}
+	}()
 
 	go main()
 
 	return stop
 }
 
+// Block until a signal is received (see Run()), then call shutdown funcs in order (for example scheduler.Shutdown)
+// with a context that expires after timeout.
+// Returns right away if a signal was already received, even if the caller read it from Run()'s channel
+func Shutdown(timeout time.Duration, shutdowns ...func(ctx context.Context) error) error {
+	if stop == nil {
+		return errors.New("docker.Run() was not called")
+	}
+
+	<-signaled
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var errs []error
+
+	for _, shutdown := range shutdowns {
+		errs = append(errs, shutdown(ctx))
+	}
+
+	return errors.Join(errs...)
+}
+
 // Exit with code
 func Exit(code int) {
 	os.Exit(code)
 
 	stop <- syscall.SIGTERM
-}
\ No newline at end of file
+}
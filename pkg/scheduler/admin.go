@@ -0,0 +1,148 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type jobView struct {
+	ID			string		`json:"id"`
+	NextRun		*time.Time	`json:"nextRun,omitempty"`
+	Repeating	bool		`json:"repeating"`
+	Paused		bool		`json:"paused"`
+	Running		int			`json:"running"`
+	Singleton	bool		`json:"singleton,omitempty"`
+	Tags		[]string	`json:"tags,omitempty"`
+	Group		string		`json:"group,omitempty"`
+	LastOutcome	Outcome		`json:"lastOutcome,omitempty"`
+	LastError	string		`json:"lastError,omitempty"`
+}
+
+// HTTP handler for operators (mount with http.StripPrefix() or httpserver.Mount()):
+//
+//	GET  /jobs               list jobs
+//	GET  /jobs/{id}          get job
+//	POST /jobs/{id}/cancel   cancel job
+//	POST /jobs/{id}/trigger  run job right away (see TriggerNow())
+//	GET  /metrics            counters and histograms (see Metrics())
+func (scheduler *Scheduler) AdminHandler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /jobs", func(w http.ResponseWriter, req *http.Request) {
+		infos := scheduler.List()
+
+		views := make([]jobView, 0, len(infos))
+
+		for _, info := range infos {
+			views = append(views, newJobView(info))
+		}
+
+		writeJSON(w, http.StatusOK, views)
+	})
+
+	// ids may contain "/" (workflow steps), so the action is cut off the end
+	mux.HandleFunc("GET /jobs/{id...}", func(w http.ResponseWriter, req *http.Request) {
+		id := req.PathValue("id")
+
+		info, ok := scheduler.Get(id)
+
+		if !ok {
+			if _, action := cutAction(id); action != "" {
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+
+			http.Error(w, "job not found", http.StatusNotFound)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, newJobView(info))
+	})
+
+	mux.HandleFunc("POST /jobs/{id...}", func(w http.ResponseWriter, req *http.Request) {
+		id, action := cutAction(req.PathValue("id"))
+
+		switch action {
+		case "cancel":
+			if !scheduler.Cancel(id) {
+				http.Error(w, "job not found", http.StatusNotFound)
+				return
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+		case "trigger":
+			if _, ok := scheduler.Get(id); !ok {
+				http.Error(w, "job not found", http.StatusNotFound)
+				return
+			}
+
+			if !scheduler.TriggerNow(id) {
+				http.Error(w, "scheduler is not running", http.StatusConflict)
+				return
+			}
+
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
+	mux.HandleFunc("GET /metrics", func(w http.ResponseWriter, req *http.Request) {
+		writeJSON(w, http.StatusOK, scheduler.Metrics())
+	})
+
+	return mux
+}
+
+func newJobView(info JobInfo) jobView {
+	view := jobView{
+		ID: info.ID,
+		Repeating: info.Repeating,
+		Paused: info.Paused,
+		Running: info.Running,
+		Singleton: info.Singleton,
+		Tags: info.Tags,
+		Group: info.Group,
+	}
+
+	if !info.NextRun.IsZero() {
+		view.NextRun = &info.NextRun
+	}
+
+	if len(info.History) > 0 {
+		last := info.History[len(info.History) - 1]
+
+		view.LastOutcome = last.Outcome
+
+		if last.Err != nil {
+			view.LastError = last.Err.Error()
+		}
+	}
+
+	return view
+}
+
+// Split "{id}/{action}" into id and action
+func cutAction(path string) (string, string) {
+	index := strings.LastIndex(path, "/")
+
+	if index < 0 {
+		return path, ""
+	}
+
+	switch action := path[index + 1:]; action {
+	case "cancel", "trigger":
+		return path[:index], action
+	}
+
+	return path, ""
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	json.NewEncoder(w).Encode(value)
+}
@@ -0,0 +1,344 @@
+package scheduler
+
+import "time"
+
+// Set of times, used to filter RepeatPolicies (see Include(), Exclude())
+type Calendar interface {
+	Contains(t time.Time) bool
+}
+
+type CalendarFunc func(t time.Time) bool
+
+func (fn CalendarFunc) Contains(t time.Time) bool {
+	return fn(t)
+}
+
+// Days of the week in location (nil => time.Local)
+func Weekdays(location *time.Location, weekdays ...time.Weekday) Calendar {
+	location = orLocal(location)
+
+	var bits uint64
+
+	for _, weekday := range weekdays {
+		bits |= 1 << uint(weekday)
+	}
+
+	return CalendarFunc(func(t time.Time) bool {
+		return hasBit(bits, int(t.In(location).Weekday()))
+	})
+}
+
+// Monday to friday in location (nil => time.Local), combine with Holidays() to exclude public holidays
+func BusinessDays(location *time.Location) Calendar {
+	return Weekdays(location, time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday)
+}
+
+// Last day of every month in location (nil => time.Local)
+func LastDayOfMonth(location *time.Location) Calendar {
+	location = orLocal(location)
+
+	return CalendarFunc(func(t time.Time) bool {
+		t = t.In(location)
+
+		return t.AddDate(0, 0, 1).Month() != t.Month()
+	})
+}
+
+// n-th weekday of every month in location (nil => time.Local), negative n counts from the end (-1 => last)
+func NthWeekday(location *time.Location, n int, weekday time.Weekday) Calendar {
+	location = orLocal(location)
+
+	return CalendarFunc(func(t time.Time) bool {
+		t = t.In(location)
+
+		if t.Weekday() != weekday {
+			return false
+		}
+
+		if n > 0 {
+			return (t.Day() - 1) / 7 + 1 == n
+		}
+
+		last := time.Date(t.Year(), t.Month() + 1, 0, 0, 0, 0, 0, time.UTC).Day()
+
+		return (last - t.Day()) / 7 + 1 == -n
+	})
+}
+
+// Whole days in location (nil => time.Local), only the year, month and day of dates are used
+func Holidays(location *time.Location, dates ...time.Time) Calendar {
+	location = orLocal(location)
+
+	days := make(map[time.Time]bool, len(dates))
+
+	for _, date := range dates {
+		days[time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)] = true
+	}
+
+	return CalendarFunc(func(t time.Time) bool {
+		t = t.In(location)
+
+		return days[time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)]
+	})
+}
+
+// Times in [start, end)
+func Window(start time.Time, end time.Time) Calendar {
+	return CalendarFunc(func(t time.Time) bool {
+		return !t.Before(start) && t.Before(end)
+	})
+}
+
+// Times of day in [from, to) in location (nil => time.Local), windows wrap around midnight if to is before from
+// (for example 22h to 6h)
+func DailyWindow(location *time.Location, from time.Duration, to time.Duration) Calendar {
+	location = orLocal(location)
+
+	return CalendarFunc(func(t time.Time) bool {
+		t = t.In(location)
+
+		offset := time.Duration(t.Hour()) * time.Hour + time.Duration(t.Minute()) * time.Minute +
+			time.Duration(t.Second()) * time.Second + time.Duration(t.Nanosecond())
+
+		if from <= to {
+			return offset >= from && offset < to
+		}
+
+		return offset >= from || offset < to
+	})
+}
+
+// Times contained in every calendar
+func AllOf(calendars ...Calendar) Calendar {
+	return CalendarFunc(func(t time.Time) bool {
+		for _, calendar := range calendars {
+			if !calendar.Contains(t) {
+				return false
+			}
+		}
+
+		return true
+	})
+}
+
+// Times contained in any calendar
+func AnyOf(calendars ...Calendar) Calendar {
+	return CalendarFunc(func(t time.Time) bool {
+		for _, calendar := range calendars {
+			if calendar.Contains(t) {
+				return true
+			}
+		}
+
+		return false
+	})
+}
+
+// Times not contained in calendar
+func Not(calendar Calendar) Calendar {
+	return CalendarFunc(func(t time.Time) bool {
+		return !calendar.Contains(t)
+	})
+}
+
+type CalendarPolicy struct {
+	Policy		RepeatPolicy
+	Calendar	Calendar
+}
+
+// Runs of policy that are contained in calendar, for example the last day of the month:
+//
+//	policy, _ := ParseCron("0 18 28-31 * *", location)
+//	Include(policy, LastDayOfMonth(location))
+func Include(policy RepeatPolicy, calendar Calendar) *CalendarPolicy {
+	return &CalendarPolicy{
+		Policy: policy,
+		Calendar: calendar,
+	}
+}
+
+// Runs of policy that aren't contained in calendar (holidays, blackout windows, ...)
+func Exclude(policy RepeatPolicy, calendar Calendar) *CalendarPolicy {
+	return Include(policy, Not(calendar))
+}
+
+func (policy *CalendarPolicy) Next(after time.Time) time.Time {
+	return policy.search(after, policy.Policy.Next(after))
+}
+
+func (policy *CalendarPolicy) Skip(after time.Time) time.Time {
+	return policy.search(after, skipPolicy(policy.Policy, after))
+}
+
+// First run from next on contained in calendar, runs outside of it are skipped
+func (policy *CalendarPolicy) search(after time.Time, next time.Time) time.Time {
+	limit := after.AddDate(cronSearchYears, 0, 0)
+
+	for !next.IsZero() && !next.After(limit) {
+		if policy.Calendar.Contains(next) {
+			return next
+		}
+
+		next = skipPolicy(policy.Policy, next)
+	}
+
+	return time.Time{}
+}
+
+func (policy *CalendarPolicy) Clone() RepeatPolicy {
+	clone := *policy
+	clone.Policy = clonePolicy(policy.Policy)
+
+	return &clone
+}
+
+type IntersectPolicy struct {
+	Policies	[]RepeatPolicy
+}
+
+// Runs shared by all policies, meant for policies with fixed slots (cron, calendars) and not intervals
+func Intersect(policies ...RepeatPolicy) *IntersectPolicy {
+	return &IntersectPolicy{
+		Policies: policies,
+	}
+}
+
+func (policy *IntersectPolicy) Next(after time.Time) time.Time {
+	return policy.search(after, RepeatPolicy.Next)
+}
+
+func (policy *IntersectPolicy) Skip(after time.Time) time.Time {
+	return policy.search(after, skipPolicy)
+}
+
+// Only the first round counts as a run, candidates that don't agree are skipped
+func (policy *IntersectPolicy) search(after time.Time, advance func(RepeatPolicy, time.Time) time.Time) time.Time {
+	if len(policy.Policies) == 0 {
+		return time.Time{}
+	}
+
+	limit := after.AddDate(cronSearchYears, 0, 0)
+
+	for !after.After(limit) {
+		var latest time.Time
+		agree := true
+
+		for i, other := range policy.Policies {
+			next := advance(other, after)
+
+			if next.IsZero() {
+				return time.Time{}
+			}
+
+			if i > 0 && !next.Equal(latest) {
+				agree = false
+			}
+
+			if next.After(latest) {
+				latest = next
+			}
+		}
+
+		if agree {
+			return latest
+		}
+
+		// every policy continues at (or after) the latest candidate
+		after = latest.Add(-time.Nanosecond)
+		advance = skipPolicy
+	}
+
+	return time.Time{}
+}
+
+func (policy *IntersectPolicy) Clone() RepeatPolicy {
+	return &IntersectPolicy{
+		Policies: clonePolicies(policy.Policies),
+	}
+}
+
+type UnionPolicy struct {
+	Policies	[]RepeatPolicy
+}
+
+// Runs of any policy (the earliest next run wins), only policies producing the winning run are advanced,
+// stateful policies need to implement ClonablePolicy for this
+func Union(policies ...RepeatPolicy) *UnionPolicy {
+	return &UnionPolicy{
+		Policies: policies,
+	}
+}
+
+func (policy *UnionPolicy) Next(after time.Time) time.Time {
+	return policy.search(after, RepeatPolicy.Next)
+}
+
+func (policy *UnionPolicy) Skip(after time.Time) time.Time {
+	return policy.search(after, skipPolicy)
+}
+
+func (policy *UnionPolicy) search(after time.Time, advance func(RepeatPolicy, time.Time) time.Time) time.Time {
+	var earliest time.Time
+
+	// preview every policy on a clone, advanced clones replace the policies that win
+	clones := clonePolicies(policy.Policies)
+	nexts := make([]time.Time, len(clones))
+
+	for i, clone := range clones {
+		nexts[i] = advance(clone, after)
+
+		if nexts[i].IsZero() {
+			continue
+		}
+
+		if earliest.IsZero() || nexts[i].Before(earliest) {
+			earliest = nexts[i]
+		}
+	}
+
+	if earliest.IsZero() {
+		return earliest
+	}
+
+	for i, next := range nexts {
+		if next.Equal(earliest) {
+			policy.Policies[i] = clones[i]
+		}
+	}
+
+	return earliest
+}
+
+func (policy *UnionPolicy) Clone() RepeatPolicy {
+	return &UnionPolicy{
+		Policies: clonePolicies(policy.Policies),
+	}
+}
+
+func clonePolicy(policy RepeatPolicy) RepeatPolicy {
+	clonable, ok := policy.(ClonablePolicy)
+
+	if ok {
+		return clonable.Clone()
+	}
+
+	return policy
+}
+
+func clonePolicies(policies []RepeatPolicy) []RepeatPolicy {
+	clones := make([]RepeatPolicy, len(policies))
+
+	for i, policy := range policies {
+		clones[i] = clonePolicy(policy)
+	}
+
+	return clones
+}
+
+func orLocal(location *time.Location) *time.Location {
+	if location == nil {
+		return time.Local
+	}
+
+	return location
+}
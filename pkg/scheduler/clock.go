@@ -0,0 +1,188 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type Clock interface {
+	Now() time.Time
+	// Call fn in its own goroutine after duration (see time.AfterFunc())
+	AfterFunc(duration time.Duration, fn func()) Timer
+}
+
+type Timer interface {
+	Stop() bool
+}
+
+// Context timing out on a Clock, context.WithTimeout() always uses the real clock
+type timeoutContext struct {
+	context.Context
+	deadline	time.Time
+}
+
+func withTimeout(parent context.Context, clock Clock, timeout time.Duration) (context.Context, context.CancelFunc) {
+	deadline := clock.Now().Add(timeout)
+
+	ctx, cancel := context.WithCancelCause(parent)
+
+	timer := clock.AfterFunc(timeout, func() {
+		cancel(context.DeadlineExceeded)
+	})
+
+	return &timeoutContext{
+		Context: ctx,
+		deadline: deadline,
+	}, func() {
+		timer.Stop()
+		cancel(nil)
+	}
+}
+
+func (ctx *timeoutContext) Deadline() (time.Time, bool) {
+	deadline, ok := ctx.Context.Deadline()
+
+	if ok && deadline.Before(ctx.deadline) {
+		return deadline, true
+	}
+
+	return ctx.deadline, true
+}
+
+func (ctx *timeoutContext) Err() error {
+	err := ctx.Context.Err()
+
+	if err != nil && context.Cause(ctx.Context) == context.DeadlineExceeded {
+		return context.DeadlineExceeded
+	}
+
+	return err
+}
+
+type realClock struct{}
+
+// Clock backed by the time package
+func RealClock() Clock {
+	return realClock{}
+}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) AfterFunc(duration time.Duration, fn func()) Timer {
+	return time.AfterFunc(duration, fn)
+}
+
+// Manually advanced Clock for tests, timers fire synchronously inside of Advance()
+type FakeClock struct {
+	mutex		sync.Mutex
+	now			time.Time
+	timers		[]*fakeTimer
+}
+
+type fakeTimer struct {
+	clock		*FakeClock
+	at			time.Time
+	fn			func()
+}
+
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{
+		now: now,
+	}
+}
+
+func (clock *FakeClock) Now() time.Time {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+
+	return clock.now
+}
+
+func (clock *FakeClock) AfterFunc(duration time.Duration, fn func()) Timer {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+
+	timer := &fakeTimer{
+		clock: clock,
+		at: clock.now.Add(duration),
+		fn: fn,
+	}
+
+	clock.timers = append(clock.timers, timer)
+
+	return timer
+}
+
+// Move clock forward by duration, firing due timers in order (including timers created while firing).
+// With a Scheduler this starts all due jobs before returning, use Scheduler.Wait() to wait for them to finish
+func (clock *FakeClock) Advance(duration time.Duration) {
+	clock.mutex.Lock()
+	target := clock.now.Add(duration)
+	clock.mutex.Unlock()
+
+	for {
+		clock.mutex.Lock()
+
+		next := -1
+
+		for i, timer := range clock.timers {
+			if timer.at.After(target) {
+				continue
+			}
+
+			if next < 0 || timer.at.Before(clock.timers[next].at) {
+				next = i
+			}
+		}
+
+		if next < 0 {
+			clock.now = target
+
+			clock.mutex.Unlock()
+			return
+		}
+
+		timer := clock.timers[next]
+		clock.timers = append(clock.timers[:next], clock.timers[next + 1:]...)
+
+		if timer.at.After(clock.now) {
+			clock.now = timer.at
+		}
+
+		clock.mutex.Unlock()
+
+		timer.fn()
+	}
+}
+
+// Move wall clock by duration without firing timers, like a suspended host or a changed system clock.
+// Timers keep their remaining duration and therefore fire late (or early for negative durations)
+func (clock *FakeClock) Jump(duration time.Duration) {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+
+	clock.now = clock.now.Add(duration)
+
+	for _, timer := range clock.timers {
+		timer.at = timer.at.Add(duration)
+	}
+}
+
+func (timer *fakeTimer) Stop() bool {
+	clock := timer.clock
+
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+
+	for i, other := range clock.timers {
+		if other == timer {
+			clock.timers = append(clock.timers[:i], clock.timers[i + 1:]...)
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,346 @@
+package scheduler
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var cronMacros = map[string]string{
+	"@yearly": "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly": "0 0 1 * *",
+	"@weekly": "0 0 * * 0",
+	"@daily": "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly": "0 * * * *",
+}
+
+var cronMonths = map[string]int{
+	"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+	"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+}
+
+var cronWeekdays = map[string]int{
+	"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+}
+
+type cronField struct {
+	name		string
+	min			int
+	max			int
+	names		map[string]int
+}
+
+var (
+	cronSecond = cronField{ name: "second", min: 0, max: 59 }
+	cronMinute = cronField{ name: "minute", min: 0, max: 59 }
+	cronHour = cronField{ name: "hour", min: 0, max: 23 }
+	cronDay = cronField{ name: "day of month", min: 1, max: 31 }
+	cronMonth = cronField{ name: "month", min: 1, max: 12, names: cronMonths }
+	// 7 is an alias for sunday
+	cronWeekday = cronField{ name: "day of week", min: 0, max: 7, names: cronWeekdays }
+)
+
+// how far Next() searches before giving up on expressions that never match (for example `0 0 30 2 *`)
+const cronSearchYears = 5
+
+type CronPolicy struct {
+	Expression		string
+	Location		*time.Location
+	seconds			uint64
+	minutes			uint64
+	hours			uint64
+	days			uint64
+	months			uint64
+	weekdays		uint64
+	anyDay			bool
+	anyWeekday		bool
+	anyHour			bool
+}
+
+// Parse cron expression with 5 (`minute hour day month weekday`) or 6 (leading `second`) fields,
+// supports `*`, `?`, ranges (`1-5`), steps (`*/15`, `0-30/5`), lists (`1,15`), names (`JAN`, `MON-FRI`)
+// and macros (`@yearly`, `@monthly`, `@weekly`, `@daily`, `@hourly`). Times are evaluated in location (nil => time.Local)
+func ParseCron(expression string, location *time.Location) (*CronPolicy, error) {
+	if location == nil {
+		location = time.Local
+	}
+
+	fields := strings.Fields(expression)
+
+	if len(fields) == 1 && strings.HasPrefix(fields[0], "@") {
+		macro, ok := cronMacros[strings.ToLower(fields[0])]
+
+		if !ok {
+			return nil, errors.New("unknown cron macro " + fields[0])
+		}
+
+		fields = strings.Fields(macro)
+	}
+
+	switch len(fields) {
+	case 5:
+		fields = append([]string{ "0" }, fields...)
+	case 6:
+	default:
+		return nil, errors.New("expected 5 or 6 cron fields, got " + strconv.Itoa(len(fields)))
+	}
+
+	policy := &CronPolicy{
+		Expression: expression,
+		Location: location,
+		anyDay: isCronWildcard(fields[3]),
+		anyWeekday: isCronWildcard(fields[5]),
+	}
+
+	var err error
+
+	targets := []*uint64{ &policy.seconds, &policy.minutes, &policy.hours, &policy.days, &policy.months, &policy.weekdays }
+	specs := []cronField{ cronSecond, cronMinute, cronHour, cronDay, cronMonth, cronWeekday }
+
+	for i, field := range fields {
+		*targets[i], err = parseCronField(field, specs[i])
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// sunday can be 0 or 7
+	if policy.weekdays & (1 << 7) != 0 {
+		policy.weekdays |= 1
+	}
+
+	policy.anyHour = policy.hours == 1 << 24 - 1
+
+	return policy, nil
+}
+
+// Next run after after. Times that don't exist because of a DST gap run at the end of the gap,
+// times that occur twice because of a DST overlap only run once (on their first occurrence),
+// unless every hour matches (`*/30 * * * *`), then both occurrences run
+func (policy *CronPolicy) Next(after time.Time) time.Time {
+	after = after.In(policy.Location)
+
+	if policy.anyHour {
+		return policy.nextInstant(after)
+	}
+
+	// search in wall clock time, represented in UTC to not be affected by DST
+	wall := wallClock(after).Add(time.Second)
+
+	limit := wall.Year() + cronSearchYears
+
+	for {
+		wall = policy.search(wall, limit)
+
+		if wall.IsZero() {
+			return wall
+		}
+
+		next := resolveWallTime(wall, policy.Location)
+
+		// already ran (repeated wall time or several times inside of the same DST gap)
+		if !next.After(after) {
+			wall = wall.Add(time.Second)
+			continue
+		}
+
+		return next
+	}
+}
+
+// Search every zone period (between two DST transitions) on its own, so that repeated wall times run twice
+func (policy *CronPolicy) nextInstant(after time.Time) time.Time {
+	from := after.Truncate(time.Second).Add(time.Second)
+
+	limit := after.Year() + cronSearchYears
+
+	for {
+		_, offset := from.Zone()
+		_, end := from.ZoneBounds()
+
+		wall := policy.search(wallClock(from), limit)
+
+		if wall.IsZero() {
+			return wall
+		}
+
+		next := wall.Add(-time.Duration(offset) * time.Second).In(policy.Location)
+
+		if end.IsZero() || next.Before(end) {
+			return next
+		}
+
+		from = end.In(policy.Location)
+	}
+}
+
+// First wall clock time (in UTC) at or after wall matching the expression, zero if there is none until limit
+func (policy *CronPolicy) search(wall time.Time, limit int) time.Time {
+	for wall.Year() <= limit {
+		if !hasBit(policy.months, int(wall.Month())) {
+			wall = time.Date(wall.Year(), wall.Month() + 1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+
+		if !policy.matchesDay(wall) {
+			wall = time.Date(wall.Year(), wall.Month(), wall.Day() + 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+
+		if !hasBit(policy.hours, wall.Hour()) {
+			wall = wall.Truncate(time.Hour).Add(time.Hour)
+			continue
+		}
+
+		if !hasBit(policy.minutes, wall.Minute()) {
+			wall = wall.Truncate(time.Minute).Add(time.Minute)
+			continue
+		}
+
+		if !hasBit(policy.seconds, wall.Second()) {
+			wall = wall.Add(time.Second)
+			continue
+		}
+
+		return wall
+	}
+
+	return time.Time{}
+}
+
+// day of month and weekday are OR-ed if both are restricted (like in standard cron)
+func (policy *CronPolicy) matchesDay(wall time.Time) bool {
+	day := hasBit(policy.days, wall.Day())
+	weekday := hasBit(policy.weekdays, int(wall.Weekday()))
+
+	switch {
+	case policy.anyDay && policy.anyWeekday:
+		return true
+	case policy.anyDay:
+		return weekday
+	case policy.anyWeekday:
+		return day
+	default:
+		return day || weekday
+	}
+}
+
+// Convert wall clock time (in UTC) to an instant in location
+func resolveWallTime(wall time.Time, location *time.Location) time.Time {
+	t := time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(), 0, location)
+
+	local := wallClock(t)
+
+	// inside of a DST gap: run when the gap ends
+	if !local.Equal(wall) {
+		start, end := t.ZoneBounds()
+
+		if local.After(wall) {
+			return start
+		}
+
+		return end
+	}
+
+	// inside of a DST overlap: prefer the first occurrence
+	start, _ := t.ZoneBounds()
+
+	if !start.IsZero() {
+		_, offset := start.Add(-time.Second).Zone()
+
+		earlier := wall.Add(-time.Duration(offset) * time.Second).In(location)
+
+		if earlier.Before(start) && wallClock(earlier).Equal(wall) {
+			return earlier
+		}
+	}
+
+	return t
+}
+
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+}
+
+func isCronWildcard(field string) bool {
+	return field == "*" || field == "?"
+}
+
+func hasBit(bits uint64, n int) bool {
+	return bits & (1 << uint(n)) != 0
+}
+
+func parseCronField(field string, spec cronField) (uint64, error) {
+	var bits uint64
+
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+
+		step := 1
+
+		if hasStep {
+			var err error
+
+			step, err = strconv.Atoi(stepPart)
+
+			if err != nil || step <= 0 {
+				return 0, errors.New("invalid " + spec.name + " step " + stepPart)
+			}
+		}
+
+		start, end := spec.min, spec.max
+
+		if !isCronWildcard(rangePart) {
+			startPart, endPart, isRange := strings.Cut(rangePart, "-")
+
+			var err error
+
+			start, err = parseCronValue(startPart, spec)
+
+			if err != nil {
+				return 0, err
+			}
+
+			switch {
+			case isRange:
+				end, err = parseCronValue(endPart, spec)
+
+				if err != nil {
+					return 0, err
+				}
+			case !hasStep:
+				end = start
+			}
+
+			if start > end {
+				return 0, errors.New("invalid " + spec.name + " range " + rangePart)
+			}
+		}
+
+		for i := start; i <= end; i += step {
+			bits |= 1 << uint(i)
+		}
+	}
+
+	return bits, nil
+}
+
+func parseCronValue(value string, spec cronField) (int, error) {
+	named, ok := spec.names[strings.ToLower(value)]
+
+	if ok {
+		return named, nil
+	}
+
+	n, err := strconv.Atoi(value)
+
+	if err != nil || n < spec.min || n > spec.max {
+		return 0, errors.New("invalid " + spec.name + " " + value)
+	}
+
+	return n, nil
+}
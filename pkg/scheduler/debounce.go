@@ -0,0 +1,183 @@
+package scheduler
+
+import (
+	"container/heap"
+	"time"
+)
+
+type debouncer struct {
+	fn			func()
+	// start of the current burst of calls
+	first		time.Time
+}
+
+type ThrottleOptions struct {
+	// run on the first call of an interval
+	Leading		bool
+	// run once more at the end of an interval with the latest fn
+	Trailing	bool
+}
+
+type throttler struct {
+	fn			func()
+	last		time.Time
+	interval	time.Duration
+}
+
+// Run fn once delay has passed without another call with the same id, only the latest fn runs
+func (scheduler *Scheduler) Debounce(id string, delay time.Duration, fn func()) {
+	scheduler.DebounceWithMaxWait(id, delay, 0, fn)
+}
+
+// Debounce (see Debounce()), but run after at most maxWait since the first call of a burst (0 => no max wait)
+func (scheduler *Scheduler) DebounceWithMaxWait(id string, delay time.Duration, maxWait time.Duration, fn func()) {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	now := scheduler.clock.Now()
+
+	state, ok := scheduler.debouncers[id]
+
+	if !ok {
+		state = &debouncer{}
+		scheduler.debouncers[id] = state
+	}
+
+	state.fn = fn
+
+	// a burst lasts as long as its job is scheduled (runs bind the latest fn when they start)
+	job, scheduled := scheduler.indexMap[id]
+	scheduled = scheduled && job.limiter == state && job.index >= 0
+
+	if !scheduled {
+		state.first = now
+	}
+
+	runAt := now.Add(delay)
+
+	if maxWait > 0 && runAt.After(state.first.Add(maxWait)) {
+		runAt = state.first.Add(maxWait)
+	}
+
+	if scheduled {
+		job.runAt = runAt
+
+		heap.Fix(&scheduler.jobs, job.index)
+		scheduler.resetTimerLocked()
+
+		return
+	}
+
+	scheduler.addJobLocked(runAt, nil, JobOptions{
+		ID: id,
+		keepRuns: true,
+		limiter: state,
+		bind: func() JobFunc {
+			// ends the burst (expects scheduler.mutex to be held)
+			fn := state.fn
+
+			delete(scheduler.debouncers, id)
+
+			return wrapFunc(fn)
+		},
+	})
+}
+
+// Run fn at most once per interval on the leading and trailing edge (see ThrottleWith())
+func (scheduler *Scheduler) Throttle(id string, interval time.Duration, fn func()) {
+	scheduler.ThrottleWith(id, interval, fn, ThrottleOptions{
+		Leading: true,
+		Trailing: true,
+	})
+}
+
+// Run fn at most once per interval, calls during an interval run once at its end (if options.Trailing)
+// with the latest fn
+func (scheduler *Scheduler) ThrottleWith(id string, interval time.Duration, fn func(), options ThrottleOptions) {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	now := scheduler.clock.Now()
+
+	state, ok := scheduler.throttlers[id]
+
+	if !ok {
+		state = &throttler{}
+	}
+
+	// merge into the next run
+	job, scheduled := scheduler.indexMap[id]
+
+	if scheduled && job.limiter == state {
+		if options.Trailing {
+			state.fn = fn
+		}
+
+		return
+	}
+
+	var runAt time.Time
+
+	switch {
+	case state.last.IsZero() || now.Sub(state.last) >= interval:
+		if options.Leading {
+			runAt = now
+		} else if options.Trailing {
+			runAt = now.Add(interval)
+		} else {
+			return
+		}
+	case options.Trailing:
+		runAt = state.last.Add(interval)
+	default:
+		return
+	}
+
+	state.fn = fn
+	state.interval = interval
+
+	scheduler.throttlers[id] = state
+
+	scheduler.addJobLocked(runAt, nil, JobOptions{
+		ID: id,
+		keepRuns: true,
+		limiter: state,
+		bind: func() JobFunc {
+			// expects scheduler.mutex to be held
+			fn := state.fn
+
+			state.fn = nil
+			state.last = scheduler.clock.Now()
+
+			scheduler.clock.AfterFunc(interval, func() {
+				scheduler.pruneThrottler(id, state)
+			})
+
+			return wrapFunc(fn)
+		},
+	})
+}
+
+// Forget throttle state once its interval has passed without another run
+func (scheduler *Scheduler) pruneThrottler(id string, state *throttler) {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	if scheduler.throttlers[id] != state || scheduler.clock.Now().Sub(state.last) < state.interval {
+		return
+	}
+
+	job, scheduled := scheduler.indexMap[id]
+
+	if scheduled && job.limiter == state {
+		return
+	}
+
+	delete(scheduler.throttlers, id)
+}
+
+// Drop debounce and throttle state of a cancelled or removed job, expects scheduler.mutex to be held
+func (scheduler *Scheduler) dropLimitersLocked(id string) {
+	delete(scheduler.debouncers, id)
+	delete(scheduler.throttlers, id)
+}
@@ -11,8 +11,7 @@ func (heap jobHeap) Less(index1, index2 int) bool {
 }
 
 func (heap jobHeap) Swap(index1, index2 int) {
-	heap[index1] = heap[index2]
-	heap[index2] = heap[index1]
+	heap[index1], heap[index2] = heap[index2], heap[index1]
 
 	// reassign index
 	heap[index1].index = index1
@@ -20,7 +19,10 @@ func (heap jobHeap) Swap(index1, index2 int) {
 }
 
 func (heap *jobHeap) Push(new any) {
-	*heap = append(*heap, new.(*Job)) 
+	job := new.(*Job)
+	job.index = len(*heap)
+
+	*heap = append(*heap, job)
 }
 
 func (heap *jobHeap) Pop() any {
@@ -32,7 +34,10 @@ func (heap *jobHeap) Pop() any {
 	job := old[oldLength - 1]
 
 	// remove last job from heap
+	old[oldLength - 1] = nil
 	*heap = old[:oldLength - 1]
 
+	job.index = -1
+
 	return job
 }
\ No newline at end of file
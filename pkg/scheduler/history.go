@@ -0,0 +1,198 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"runtime/debug"
+	"slices"
+	"time"
+)
+
+type Outcome string
+
+const (
+	OutcomeSucceeded Outcome = "succeeded"
+	OutcomeFailed Outcome = "failed"
+	OutcomePanicked Outcome = "panicked"
+	OutcomeTimedOut Outcome = "timed out"
+	OutcomeCancelled Outcome = "cancelled"
+	// run was dropped by MisfireSkip
+	OutcomeMissed Outcome = "missed"
+)
+
+type RunRecord struct {
+	Start		time.Time
+	End			time.Time
+	Duration	time.Duration
+	Outcome		Outcome
+	Err			error
+	// 0 for the first attempt, incremented with every retry
+	Attempt		int
+}
+
+// Retry failed (or panicked) runs with exponential backoff (initial, initial * factor, ...) capped at max
+type RetryPolicy struct {
+	// max retries after the first attempt, 0 => no retries
+	Attempts	int
+	Initial		time.Duration
+	// 0 => no cap
+	Max			time.Duration
+	// 0 => 2
+	Factor		float64
+}
+
+// Delay before retry attempt (starting at 1)
+func (policy RetryPolicy) Delay(attempt int) time.Duration {
+	factor := policy.Factor
+
+	if factor <= 0 {
+		factor = 2
+	}
+
+	delay := time.Duration(float64(policy.Initial) * math.Pow(factor, float64(attempt - 1)))
+
+	if policy.Max > 0 && (delay > policy.Max || delay < 0) {
+		delay = policy.Max
+	}
+
+	return delay
+}
+
+// Returned for runs that panicked
+type PanicError struct {
+	Value		any
+	Stack		[]byte
+}
+
+func (err *PanicError) Error() string {
+	return "job panicked: " + fmt.Sprint(err.Value)
+}
+
+// Get run history of job (oldest first), finished jobs are kept for Options.Retain jobs
+func (scheduler *Scheduler) History(id string) ([]RunRecord, bool) {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	job, ok := scheduler.indexMap[id]
+
+	if !ok {
+		job, ok = scheduler.active[id]
+	}
+
+	if ok {
+		return slices.Clone(job.history), true
+	}
+
+	history, ok := scheduler.finished[id]
+
+	return slices.Clone(history), ok
+}
+
+// Call fn and turn panics into *PanicError
+func call(ctx context.Context, fn JobFunc) (err error) {
+	defer func() {
+		value := recover()
+
+		if value != nil {
+			err = &PanicError{
+				Value: value,
+				Stack: debug.Stack(),
+			}
+		}
+	}()
+
+	return fn(ctx)
+}
+
+func outcomeOf(ctx context.Context, err error) Outcome {
+	var panicErr *PanicError
+
+	switch {
+	case err == nil:
+		return OutcomeSucceeded
+	case errors.As(err, &panicErr):
+		return OutcomePanicked
+	case ctx.Err() != nil && errors.Is(err, context.DeadlineExceeded):
+		return OutcomeTimedOut
+	case ctx.Err() != nil && errors.Is(err, context.Canceled):
+		return OutcomeCancelled
+	default:
+		return OutcomeFailed
+	}
+}
+
+// Expects scheduler.mutex to be held
+func (scheduler *Scheduler) recordLocked(job *Job, record RunRecord) {
+	scheduler.observeLocked(record)
+
+	limit := job.historySize
+
+	if limit <= 0 {
+		return
+	}
+
+	job.history = append(job.history, record)
+
+	if len(job.history) > limit {
+		job.history = slices.Delete(job.history, 0, len(job.history) - limit)
+	}
+}
+
+// Keep history of job that left the scheduler, expects scheduler.mutex to be held
+func (scheduler *Scheduler) retainLocked(job *Job) {
+	if scheduler.options.Retain <= 0 || len(job.history) == 0 {
+		return
+	}
+
+	_, exists := scheduler.finished[job.id]
+
+	if !exists {
+		scheduler.finishedOrder = append(scheduler.finishedOrder, job.id)
+	}
+
+	scheduler.finished[job.id] = job.history
+
+	for len(scheduler.finishedOrder) > scheduler.options.Retain {
+		delete(scheduler.finished, scheduler.finishedOrder[0])
+
+		scheduler.finishedOrder = scheduler.finishedOrder[1:]
+	}
+}
+
+// Wait delay and queue run again, expects scheduler.mutex to be held
+func (scheduler *Scheduler) retryLocked(run *jobRun, delay time.Duration) {
+	run.waiting = true
+	scheduler.retrying++
+
+	var timer Timer
+	var stop func() bool
+
+	wake := func() {
+		scheduler.mutex.Lock()
+		defer scheduler.mutex.Unlock()
+
+		if !run.waiting {
+			return
+		}
+
+		run.waiting = false
+		scheduler.retrying--
+
+		timer.Stop()
+		stop()
+
+		// no retries while shutting down
+		if scheduler.closed {
+			run.cancel()
+		}
+
+		// cancelled runs are dispatched right away and finish without running
+		scheduler.pending = append(scheduler.pending, run)
+		scheduler.dispatchLocked()
+	}
+
+	timer = scheduler.clock.AfterFunc(delay, wake)
+	stop = context.AfterFunc(run.ctx, wake)
+}
@@ -0,0 +1,307 @@
+package scheduler
+
+import (
+	"container/heap"
+	"slices"
+	"sort"
+	"time"
+)
+
+// Immutable snapshot of a job
+type JobInfo struct {
+	ID			string
+	// zero if the job isn't scheduled anymore (only running)
+	NextRun		time.Time
+	Repeating	bool
+	Paused		bool
+	// number of in-flight runs
+	Running		int
+	Handler		string
+	Timeout		time.Duration
+	Overlap		OverlapPolicy
+	Singleton	bool
+	Tags		[]string
+	Group		string
+	History		[]RunRecord
+}
+
+// Implemented by stateful RepeatPolicies, so that NextRuns() can preview runs without changing their state
+type ClonablePolicy interface {
+	RepeatPolicy
+	Clone() RepeatPolicy
+}
+
+// Implemented by RepeatPolicies counting runs, Skip() advances past a slot that didn't run (missed or realigned)
+type SkippablePolicy interface {
+	RepeatPolicy
+	Skip(after time.Time) time.Time
+}
+
+// Snapshots of all scheduled and running jobs, sorted by NextRun (running jobs last)
+func (scheduler *Scheduler) List() []JobInfo {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	jobs := make([]*Job, 0, len(scheduler.indexMap))
+
+	for _, job := range scheduler.indexMap {
+		jobs = append(jobs, job)
+	}
+
+	for id, job := range scheduler.active {
+		if scheduler.indexMap[id] != job {
+			jobs = append(jobs, job)
+		}
+	}
+
+	return scheduler.snapshotsLocked(jobs)
+}
+
+// Snapshot of scheduled or running job
+func (scheduler *Scheduler) Get(id string) (JobInfo, bool) {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	job, ok := scheduler.lookupLocked(id)
+
+	if !ok {
+		return JobInfo{}, false
+	}
+
+	return scheduler.snapshotLocked(job), true
+}
+
+// Move next run of job to runAt
+func (scheduler *Scheduler) Reschedule(id string, runAt time.Time) bool {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	job, ok := scheduler.indexMap[id]
+
+	if !ok {
+		return false
+	}
+
+	job.runAt = runAt
+
+	if job.index >= 0 {
+		heap.Fix(&scheduler.jobs, job.index)
+		scheduler.resetTimerLocked()
+	}
+
+	return true
+}
+
+// Run job right away (according to its OverlapPolicy) without changing its schedule,
+// returns false if the job doesn't exist or the scheduler isn't running
+func (scheduler *Scheduler) TriggerNow(id string) bool {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	job, ok := scheduler.lookupLocked(id)
+
+	if !ok || scheduler.ctx == nil || scheduler.ctx.Err() != nil || job.ctx.Err() != nil {
+		return false
+	}
+
+	scheduler.startLocked(scheduler.ctx, job)
+
+	return true
+}
+
+// Preview next n runs of job, stateful policies need to implement ClonablePolicy to not be advanced
+func (scheduler *Scheduler) NextRuns(id string, n int) []time.Time {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	job, ok := scheduler.indexMap[id]
+
+	if !ok || n <= 0 {
+		return nil
+	}
+
+	runs := []time.Time{ job.runAt }
+
+	repeat := clonePolicy(job.repeat)
+
+	for repeat != nil && len(runs) < n {
+		next := repeat.Next(runs[len(runs) - 1])
+
+		if next.IsZero() {
+			break
+		}
+
+		runs = append(runs, next)
+	}
+
+	return runs
+}
+
+// Stop firing job until Resume() is called, in-flight runs keep running
+func (scheduler *Scheduler) Pause(id string) bool {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	job, ok := scheduler.indexMap[id]
+
+	if !ok {
+		return false
+	}
+
+	scheduler.pauseLocked(job)
+	scheduler.resetTimerLocked()
+
+	return true
+}
+
+// Resume paused job, missed runs of repeating jobs are skipped, missed one-off jobs run right away
+func (scheduler *Scheduler) Resume(id string) bool {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	job, ok := scheduler.indexMap[id]
+
+	if !ok {
+		return false
+	}
+
+	scheduler.resumeLocked(job)
+	scheduler.resetTimerLocked()
+
+	return true
+}
+
+// Returns false if job was already paused, expects scheduler.mutex to be held
+func (scheduler *Scheduler) pauseLocked(job *Job) bool {
+	if job.paused {
+		return false
+	}
+
+	job.paused = true
+
+	heap.Remove(&scheduler.jobs, job.index)
+
+	return true
+}
+
+// Returns false if job wasn't paused, expects scheduler.mutex to be held
+func (scheduler *Scheduler) resumeLocked(job *Job) bool {
+	if !job.paused {
+		return false
+	}
+
+	job.paused = false
+
+	scheduler.realignLocked(job, scheduler.clock.Now())
+
+	heap.Push(&scheduler.jobs, job)
+
+	return true
+}
+
+// Stop firing any job until ResumeAll() is called, in-flight runs keep running
+func (scheduler *Scheduler) PauseAll() {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	scheduler.paused = true
+	scheduler.resetTimerLocked()
+}
+
+// Resume scheduler (see Resume())
+func (scheduler *Scheduler) ResumeAll() {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	if !scheduler.paused {
+		return
+	}
+
+	scheduler.paused = false
+
+	now := scheduler.clock.Now()
+
+	for _, job := range scheduler.jobs {
+		scheduler.realignLocked(job, now)
+	}
+
+	heap.Init(&scheduler.jobs)
+	scheduler.resetTimerLocked()
+}
+
+// Skip missed runs of repeating job, expects scheduler.mutex to be held
+func (scheduler *Scheduler) realignLocked(job *Job, now time.Time) {
+	if job.repeat == nil {
+		return
+	}
+
+	for job.runAt.Before(now) {
+		next := skipPolicy(job.repeat, job.runAt)
+
+		// policy is done, run one last time
+		if next.IsZero() {
+			return
+		}
+
+		job.runAt = next
+	}
+}
+
+// Find scheduled or running job, expects scheduler.mutex to be held
+func (scheduler *Scheduler) lookupLocked(id string) (*Job, bool) {
+	job, ok := scheduler.indexMap[id]
+
+	if !ok {
+		job, ok = scheduler.active[id]
+	}
+
+	return job, ok
+}
+
+// Expects scheduler.mutex to be held
+func (scheduler *Scheduler) snapshotLocked(job *Job) JobInfo {
+	info := JobInfo{
+		ID: job.id,
+		Repeating: job.repeat != nil,
+		Paused: job.paused,
+		Running: len(job.runs),
+		Handler: job.handler,
+		Timeout: job.timeout,
+		Overlap: job.overlap,
+		Singleton: job.singleton,
+		Tags: slices.Clone(job.tags),
+		Group: job.group,
+		History: slices.Clone(job.history),
+	}
+
+	if scheduler.indexMap[job.id] == job {
+		info.NextRun = job.runAt
+	}
+
+	return info
+}
+
+// Expects scheduler.mutex to be held
+func (scheduler *Scheduler) snapshotsLocked(jobs []*Job) []JobInfo {
+	infos := make([]JobInfo, 0, len(jobs))
+
+	for _, job := range jobs {
+		infos = append(infos, scheduler.snapshotLocked(job))
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		a, b := infos[i], infos[j]
+
+		if a.NextRun.IsZero() != b.NextRun.IsZero() {
+			return !a.NextRun.IsZero()
+		}
+
+		if !a.NextRun.Equal(b.NextRun) {
+			return a.NextRun.Before(b.NextRun)
+		}
+
+		return a.ID < b.ID
+	})
+
+	return infos
+}
@@ -0,0 +1,259 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Shared lock used to run singleton jobs on a single instance only (see JobOptions.Singleton)
+type LockBackend interface {
+	// Acquire or renew lock for owner until expires,
+	// returns false if the lock is held by another owner whose lease didn't expire at now
+	TryLock(key string, owner string, now time.Time, expires time.Time) (bool, error)
+	// Release lock if it is held by owner
+	Unlock(key string, owner string) error
+}
+
+type LockError struct {
+	Key		string
+	Err		error
+}
+
+func (err *LockError) Error() string {
+	return "lock " + err.Key + ": " + err.Err.Error()
+}
+
+func (err *LockError) Unwrap() error {
+	return err.Err
+}
+
+// Returns true if this instance holds the lock (always true without Options.Lock)
+func (scheduler *Scheduler) HoldsLock() bool {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	return scheduler.holdsLockLocked()
+}
+
+// Expects scheduler.mutex to be held
+func (scheduler *Scheduler) holdsLockLocked() bool {
+	if scheduler.options.Lock == nil {
+		return true
+	}
+
+	return scheduler.leaseUntil.After(scheduler.clock.Now())
+}
+
+// Acquire or renew lease and schedule the next renewal, errors are reported to the result hook (see OnResult())
+func (scheduler *Scheduler) renewLease(ctx context.Context) {
+	scheduler.mutex.Lock()
+
+	if scheduler.ctx != ctx || ctx.Err() != nil || scheduler.options.Lock == nil {
+		scheduler.mutex.Unlock()
+		return
+	}
+
+	backend := scheduler.options.Lock
+	key := scheduler.options.LockKey
+	owner := scheduler.options.Instance
+	ttl := scheduler.options.LockTTL
+
+	now := scheduler.clock.Now()
+
+	scheduler.mutex.Unlock()
+
+	ok, err := backend.TryLock(key, owner, now, now.Add(ttl))
+
+	scheduler.mutex.Lock()
+
+	// stopped while acquiring
+	if scheduler.ctx != ctx {
+		scheduler.mutex.Unlock()
+
+		if ok {
+			backend.Unlock(key, owner)
+		}
+
+		return
+	}
+
+	switch {
+	case err != nil:
+		// keep running until the current lease expires
+	case ok:
+		scheduler.leaseUntil = now.Add(ttl)
+	default:
+		scheduler.leaseUntil = time.Time{}
+	}
+
+	// lease expired or another instance took over
+	if !scheduler.holdsLockLocked() {
+		for _, job := range scheduler.active {
+			if !job.singleton {
+				continue
+			}
+
+			for _, run := range job.runs {
+				run.cancel()
+			}
+		}
+	}
+
+	scheduler.lockTimer = scheduler.clock.AfterFunc(ttl / 3, func() {
+		scheduler.renewLease(ctx)
+	})
+
+	resultFunc := scheduler.resultFunc
+
+	scheduler.mutex.Unlock()
+
+	if err != nil && resultFunc != nil {
+		resultFunc(key, &LockError{ Key: key, Err: err })
+	}
+}
+
+// Stop renewing and release lease, returns the release (nil if there is nothing to release),
+// expects scheduler.mutex to be held
+func (scheduler *Scheduler) releaseLeaseLocked() func() {
+	if scheduler.lockTimer != nil {
+		scheduler.lockTimer.Stop()
+		scheduler.lockTimer = nil
+	}
+
+	if scheduler.options.Lock == nil || scheduler.leaseUntil.IsZero() {
+		return nil
+	}
+
+	scheduler.leaseUntil = time.Time{}
+
+	backend := scheduler.options.Lock
+	key := scheduler.options.LockKey
+	owner := scheduler.options.Instance
+
+	return func() {
+		backend.Unlock(key, owner)
+	}
+}
+
+type fileLease struct {
+	Owner		string		`json:"owner"`
+	Expires		time.Time	`json:"expires"`
+}
+
+// LockBackend backed by a JSON file of leases, changes are guarded by flock, so that instances on the same host
+// (or a shared volume supporting flock) can share it
+type FileLock struct {
+	path		string
+	mutex		sync.Mutex
+}
+
+func NewFileLock(path string) *FileLock {
+	return &FileLock{
+		path: path,
+	}
+}
+
+func (lock *FileLock) TryLock(key string, owner string, now time.Time, expires time.Time) (bool, error) {
+	acquired := false
+
+	err := lock.update(func(leases map[string]fileLease) bool {
+		lease, held := leases[key]
+
+		if held && lease.Owner != owner && lease.Expires.After(now) {
+			return false
+		}
+
+		leases[key] = fileLease{
+			Owner: owner,
+			Expires: expires,
+		}
+
+		acquired = true
+
+		return true
+	})
+
+	return acquired, err
+}
+
+func (lock *FileLock) Unlock(key string, owner string) error {
+	return lock.update(func(leases map[string]fileLease) bool {
+		lease, held := leases[key]
+
+		if !held || lease.Owner != owner {
+			return false
+		}
+
+		delete(leases, key)
+
+		return true
+	})
+}
+
+// Read, change and write leases while holding the file lock, fn returns true if leases changed
+func (lock *FileLock) update(fn func(leases map[string]fileLease) bool) error {
+	lock.mutex.Lock()
+	defer lock.mutex.Unlock()
+
+	file, err := os.OpenFile(lock.path, os.O_RDWR | os.O_CREATE, 0644)
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	err = lockFile(file)
+
+	if err != nil {
+		return err
+	}
+
+	defer unlockFile(file)
+
+	data, err := io.ReadAll(file)
+
+	if err != nil {
+		return err
+	}
+
+	leases := map[string]fileLease{}
+
+	if len(data) > 0 {
+		err = json.Unmarshal(data, &leases)
+
+		if err != nil {
+			return errors.New("invalid lock file " + lock.path + ": " + err.Error())
+		}
+	}
+
+	if !fn(leases) {
+		return nil
+	}
+
+	data, err = json.Marshal(leases)
+
+	if err != nil {
+		return err
+	}
+
+	// rewrite in place, replacing the file would break the lock of other instances
+	err = file.Truncate(0)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = file.WriteAt(data, 0)
+
+	if err != nil {
+		return err
+	}
+
+	return file.Sync()
+}
@@ -0,0 +1,17 @@
+//go:build !unix
+
+package scheduler
+
+import (
+	"errors"
+	"os"
+	"runtime"
+)
+
+func lockFile(file *os.File) error {
+	return errors.New("file locks are not supported on " + runtime.GOOS)
+}
+
+func unlockFile(file *os.File) error {
+	return nil
+}
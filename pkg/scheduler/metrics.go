@@ -0,0 +1,144 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"slices"
+	"time"
+)
+
+var defaultBuckets = []time.Duration{
+	time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	time.Second,
+	10 * time.Second,
+	time.Minute,
+	10 * time.Minute,
+}
+
+// Immutable snapshot of scheduler counters
+type Metrics struct {
+	// started runs (including triggered runs, excluding retries)
+	Fired		uint64		`json:"fired"`
+	// finished attempts (including retries)
+	Attempts	uint64		`json:"attempts"`
+	// runs whose last attempt succeeded
+	Succeeded	uint64		`json:"succeeded"`
+	// runs whose last attempt failed, panicked or timed out
+	Failed		uint64		`json:"failed"`
+	// runs whose last attempt was cancelled
+	Cancelled	uint64		`json:"cancelled"`
+	// runs that didn't start because of OverlapSkip, MisfireSkip or another instance holding the lock
+	Skipped		uint64		`json:"skipped"`
+	// duration of attempts
+	Durations	Histogram	`json:"durations"`
+	// delay between runAt and the actual firing
+	Lateness	Histogram	`json:"lateness"`
+}
+
+type Histogram struct {
+	// upper bounds (inclusive), sorted
+	Buckets		[]time.Duration
+	// observations per bucket, the last count holds observations above every bound
+	Counts		[]uint64
+	Count		uint64
+	Sum			time.Duration
+}
+
+func newHistogram(buckets []time.Duration) Histogram {
+	return Histogram{
+		Buckets: buckets,
+		Counts: make([]uint64, len(buckets) + 1),
+	}
+}
+
+func (histogram *Histogram) observe(value time.Duration) {
+	i, _ := slices.BinarySearch(histogram.Buckets, value)
+
+	histogram.Counts[i]++
+	histogram.Count++
+	histogram.Sum += value
+}
+
+// Mean of all observations
+func (histogram Histogram) Mean() time.Duration {
+	if histogram.Count == 0 {
+		return 0
+	}
+
+	return histogram.Sum / time.Duration(histogram.Count)
+}
+
+func (histogram Histogram) clone() Histogram {
+	histogram.Counts = slices.Clone(histogram.Counts)
+
+	return histogram
+}
+
+type histogramBucket struct {
+	LessOrEqual		string		`json:"le"`
+	Count			uint64		`json:"count"`
+}
+
+func (histogram Histogram) MarshalJSON() ([]byte, error) {
+	buckets := make([]histogramBucket, 0, len(histogram.Counts))
+
+	for i, count := range histogram.Counts {
+		bound := "+Inf"
+
+		if i < len(histogram.Buckets) {
+			bound = histogram.Buckets[i].String()
+		}
+
+		buckets = append(buckets, histogramBucket{
+			LessOrEqual: bound,
+			Count: count,
+		})
+	}
+
+	return json.Marshal(struct {
+		Count		uint64				`json:"count"`
+		Sum			string				`json:"sum"`
+		Buckets		[]histogramBucket	`json:"buckets"`
+	}{
+		Count: histogram.Count,
+		Sum: histogram.Sum.String(),
+		Buckets: buckets,
+	})
+}
+
+// Snapshot of counters since the scheduler was created
+func (scheduler *Scheduler) Metrics() Metrics {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	metrics := scheduler.metrics
+
+	metrics.Durations = metrics.Durations.clone()
+	metrics.Lateness = metrics.Lateness.clone()
+
+	return metrics
+}
+
+// Count finished attempt, expects scheduler.mutex to be held
+func (scheduler *Scheduler) observeLocked(record RunRecord) {
+	if record.Outcome == OutcomeMissed {
+		scheduler.metrics.Skipped++
+		return
+	}
+
+	scheduler.metrics.Attempts++
+	scheduler.metrics.Durations.observe(record.Duration)
+}
+
+// Count finished run by the outcome of its last attempt, expects scheduler.mutex to be held
+func (scheduler *Scheduler) finishLocked(outcome Outcome) {
+	switch outcome {
+	case OutcomeSucceeded:
+		scheduler.metrics.Succeeded++
+	case OutcomeCancelled:
+		scheduler.metrics.Cancelled++
+	default:
+		scheduler.metrics.Failed++
+	}
+}
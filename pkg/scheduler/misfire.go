@@ -0,0 +1,53 @@
+package scheduler
+
+import "time"
+
+// Set clock jump hook, called when the timer fires off by more than Options.ClockJumpThreshold
+// (actual is before expected if the wall clock was set back)
+func (scheduler *Scheduler) OnClockJump(jumpFunc func(expected time.Time, actual time.Time)) {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	scheduler.jumpFunc = jumpFunc
+}
+
+// Returns the call of the clock jump hook (nil if there was no jump), expects scheduler.mutex to be held
+func (scheduler *Scheduler) detectJumpLocked(expected time.Time, actual time.Time) func() {
+	threshold := scheduler.options.ClockJumpThreshold
+	jumpFunc := scheduler.jumpFunc
+
+	if threshold <= 0 || jumpFunc == nil {
+		return nil
+	}
+
+	// wall times, a changed wall clock doesn't move monotonic readings
+	drift := actual.Round(0).Sub(expected.Round(0))
+
+	if drift < threshold && drift > -threshold {
+		return nil
+	}
+
+	return func() {
+		jumpFunc(expected, actual)
+	}
+}
+
+// Advance next past now (zero if policy is done)
+func skipMissed(policy RepeatPolicy, next time.Time, now time.Time) time.Time {
+	for !next.IsZero() && !next.After(now) {
+		next = skipPolicy(policy, next)
+	}
+
+	return next
+}
+
+// Next slot after a slot that didn't run
+func skipPolicy(policy RepeatPolicy, after time.Time) time.Time {
+	skippable, ok := policy.(SkippablePolicy)
+
+	if ok {
+		return skippable.Skip(after)
+	}
+
+	return policy.Next(after)
+}
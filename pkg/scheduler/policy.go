@@ -0,0 +1,152 @@
+package scheduler
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+type IntervalPolicy struct {
+	Interval	time.Duration
+}
+
+// Repeat every interval
+func Interval(interval time.Duration) *IntervalPolicy {
+	return &IntervalPolicy{
+		Interval: interval,
+	}
+}
+
+func (policy *IntervalPolicy) Next(after time.Time) time.Time {
+	return after.Add(policy.Interval)
+}
+
+type JitterPolicy struct {
+	Interval	time.Duration
+	Jitter		time.Duration
+	slot		time.Time
+	last		time.Time
+}
+
+// Repeat every interval plus a random delay in [0, jitter), used to spread out jobs,
+// keeps state and therefore must not be shared between jobs
+func Jitter(interval time.Duration, jitter time.Duration) *JitterPolicy {
+	return &JitterPolicy{
+		Interval: interval,
+		Jitter: jitter,
+	}
+}
+
+func (policy *JitterPolicy) Next(after time.Time) time.Time {
+	slot := after
+
+	// continue from the unjittered slot of the previous run, so that jitter doesn't add up
+	if !policy.last.IsZero() && after.Equal(policy.last) {
+		slot = policy.slot
+	}
+
+	policy.slot = slot.Add(policy.Interval)
+
+	next := policy.slot
+
+	if policy.Jitter > 0 {
+		next = next.Add(rand.N(policy.Jitter))
+	}
+
+	policy.last = next
+
+	return next
+}
+
+func (policy *JitterPolicy) Clone() RepeatPolicy {
+	clone := *policy
+
+	return &clone
+}
+
+type BackoffPolicy struct {
+	Initial		time.Duration
+	Max			time.Duration
+	Factor		float64
+	current		time.Duration
+}
+
+// Repeat with exponentially growing delays (initial, initial * factor, ...) capped at max,
+// keeps state and therefore must not be shared between jobs
+func Backoff(initial time.Duration, max time.Duration, factor float64) *BackoffPolicy {
+	if factor < 1 {
+		factor = 2
+	}
+
+	return &BackoffPolicy{
+		Initial: initial,
+		Max: max,
+		Factor: factor,
+	}
+}
+
+func (policy *BackoffPolicy) Next(after time.Time) time.Time {
+	if policy.current == 0 {
+		policy.current = policy.Initial
+	} else {
+		policy.current = time.Duration(float64(policy.current) * policy.Factor)
+	}
+
+	if policy.Max > 0 && (policy.current > policy.Max || policy.current <= 0) {
+		policy.current = policy.Max
+	}
+
+	return after.Add(policy.current)
+}
+
+func (policy *BackoffPolicy) Clone() RepeatPolicy {
+	clone := *policy
+
+	return &clone
+}
+
+// Start over at initial delay
+func (policy *BackoffPolicy) Reset() {
+	policy.current = 0
+}
+
+type TimesPolicy struct {
+	Times		int
+	Policy		RepeatPolicy
+	runs		int
+}
+
+// Run job n times in total (following policy) and then stop,
+// keeps state and therefore must not be shared between jobs
+func Times(n int, policy RepeatPolicy) *TimesPolicy {
+	return &TimesPolicy{
+		Times: n,
+		Policy: policy,
+	}
+}
+
+func (policy *TimesPolicy) Next(after time.Time) time.Time {
+	// first run already happened
+	policy.runs++
+
+	if policy.runs >= policy.Times {
+		return time.Time{}
+	}
+
+	return policy.Policy.Next(after)
+}
+
+// Next slot without counting the slot at after as a run
+func (policy *TimesPolicy) Skip(after time.Time) time.Time {
+	if policy.runs >= policy.Times {
+		return time.Time{}
+	}
+
+	return skipPolicy(policy.Policy, after)
+}
+
+func (policy *TimesPolicy) Clone() RepeatPolicy {
+	clone := *policy
+	clone.Policy = clonePolicy(policy.Policy)
+
+	return &clone
+}
@@ -0,0 +1,220 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"slices"
+)
+
+type OverlapPolicy int
+
+const (
+	// start another run next to the running one
+	OverlapAllow OverlapPolicy = iota
+	// skip runs while the job is still running
+	OverlapSkip
+	// run once more after the running job finishes (at most one run is queued)
+	OverlapQueue
+	// cancel the running job and start a new run
+	OverlapReplace
+)
+
+type jobRun struct {
+	job		*Job
+	fn		JobFunc
+	parent	context.Context
+	ctx		context.Context
+	cancel	context.CancelFunc
+	attempt	int
+	// waiting for retry
+	waiting	bool
+}
+
+// Start run of job according to its OverlapPolicy, expects scheduler.mutex to be held
+func (scheduler *Scheduler) startLocked(ctx context.Context, job *Job) {
+	if scheduler.closed {
+		return
+	}
+
+	// another instance runs singleton jobs
+	if job.singleton && !scheduler.holdsLockLocked() {
+		scheduler.metrics.Skipped++
+		return
+	}
+
+	if len(job.runs) > 0 {
+		switch job.overlap {
+		case OverlapSkip:
+			scheduler.metrics.Skipped++
+			return
+		case OverlapQueue:
+			job.queued = true
+			return
+		case OverlapReplace:
+			for _, run := range job.runs {
+				run.cancel()
+			}
+		}
+	}
+
+	// cancel run if either the scheduler stops or the job is cancelled
+	runCtx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(job.ctx, cancel)
+
+	fn := job.fn
+
+	if job.bind != nil {
+		fn = job.bind()
+	}
+
+	run := &jobRun{
+		job: job,
+		fn: fn,
+		parent: ctx,
+		ctx: runCtx,
+		cancel: func() {
+			stop()
+			cancel()
+		},
+	}
+
+	job.runs = append(job.runs, run)
+	scheduler.active[job.id] = job
+
+	scheduler.metrics.Fired++
+
+	scheduler.pending = append(scheduler.pending, run)
+	scheduler.dispatchLocked()
+}
+
+// Start pending runs while workers are available, expects scheduler.mutex to be held
+func (scheduler *Scheduler) dispatchLocked() {
+	for len(scheduler.pending) > 0 {
+		if scheduler.options.MaxConcurrency > 0 && scheduler.workers >= scheduler.options.MaxConcurrency {
+			return
+		}
+
+		run := scheduler.pending[0]
+
+		scheduler.pending[0] = nil
+		scheduler.pending = scheduler.pending[1:]
+
+		scheduler.workers++
+
+		go scheduler.execute(run)
+	}
+}
+
+func (scheduler *Scheduler) execute(run *jobRun) {
+	job := run.job
+
+	ctx := run.ctx
+
+	if job.timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = withTimeout(ctx, scheduler.clock, job.timeout)
+		defer cancel()
+	}
+
+	start := scheduler.clock.Now()
+
+	// runs that were cancelled while waiting for a worker don't start
+	err := ctx.Err()
+
+	if err == nil {
+		err = call(ctx, run.fn)
+	}
+
+	end := scheduler.clock.Now()
+
+	scheduler.mutex.Lock()
+
+	scheduler.workers--
+
+	outcome := outcomeOf(ctx, err)
+
+	scheduler.recordLocked(job, RunRecord{
+		Start: start,
+		End: end,
+		Duration: end.Sub(start),
+		Outcome: outcome,
+		Err: err,
+		Attempt: run.attempt,
+	})
+
+	// retry errors and panics, but not cancelled runs
+	retry := err != nil && run.ctx.Err() == nil && run.attempt < job.retry.Attempts
+
+	// no retries while shutting down
+	if retry && scheduler.closed {
+		retry = false
+
+		scheduler.dropped = append(scheduler.dropped, job.id)
+	}
+
+	if retry {
+		run.attempt++
+
+		scheduler.retryLocked(run, job.retry.Delay(run.attempt))
+		scheduler.dispatchLocked()
+
+		if !scheduler.busyLocked() {
+			scheduler.idle.Broadcast()
+		}
+
+		scheduler.mutex.Unlock()
+		return
+	}
+
+	scheduler.finishLocked(outcome)
+
+	run.cancel()
+
+	job.runs = slices.DeleteFunc(job.runs, func(other *jobRun) bool {
+		return other == run
+	})
+
+	if len(job.runs) == 0 && job.queued {
+		job.queued = false
+
+		if job.ctx.Err() == nil && run.parent.Err() == nil {
+			scheduler.startLocked(run.parent, job)
+		}
+	}
+
+	// queued run may not have started (shutdown, lock lost)
+	if len(job.runs) == 0 && scheduler.active[job.id] == job {
+		delete(scheduler.active, job.id)
+	}
+
+	// before waking up Wait(), so that jobs started by done are waited for as well
+	if len(job.runs) == 0 && scheduler.indexMap[job.id] != job && job.done != nil {
+		job.done(err)
+	}
+
+	scheduler.dispatchLocked()
+
+	if !scheduler.busyLocked() {
+		scheduler.idle.Broadcast()
+	}
+
+	// keep jobs interrupted by a stopping scheduler, so that they run again after restoring
+	finished := len(job.runs) == 0 && scheduler.indexMap[job.id] != job && job.ctx.Err() == nil && run.parent.Err() == nil
+
+	if len(job.runs) == 0 && scheduler.indexMap[job.id] != job {
+		scheduler.leaveLocked(job)
+	}
+
+	resultFunc := scheduler.resultFunc
+
+	scheduler.mutex.Unlock()
+
+	if finished {
+		err = errors.Join(err, scheduler.forget(job))
+	}
+
+	if resultFunc != nil {
+		resultFunc(job.id, err)
+	}
+}
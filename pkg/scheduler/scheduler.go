@@ -3,18 +3,103 @@ package scheduler
 import (
 	"container/heap"
 	"context"
+	"crypto/rand"
+	"errors"
+	"slices"
 	"sync"
 	"time"
 )
 
+// Job function, ctx is cancelled when the job is cancelled, times out or the scheduler stops
+type JobFunc func(ctx context.Context) error
+
+type JobOptions struct {
+	// generated if empty
+	ID			string
+	Repeat		RepeatPolicy
+	// per run, 0 => no timeout
+	Timeout		time.Duration
+	// what happens if the job is due while it is still running
+	Overlap		OverlapPolicy
+	// what happens if a persistent job was missed while the scheduler was down
+	Misfire		MisfirePolicy
+	Retry		RetryPolicy
+	// number of runs kept in history, 0 => Options.History
+	History		int
+	Tags		[]string
+	Group		string
+	// only run on the instance holding Options.Lock
+	Singleton	bool
+	// handler name of persistent jobs
+	handler		string
+	// called when a run starts, returns the function to run instead of fn
+	bind		func() JobFunc
+	// called once the job is done (last run finished), expects scheduler.mutex to be held
+	done		func(err error)
+	// in-flight runs of a replaced job keep running (debounce, throttle)
+	keepRuns	bool
+	// *debouncer or *throttler owning the job
+	limiter		any
+}
+
+type Options struct {
+	// max number of jobs running at the same time, 0 => unlimited
+	MaxConcurrency	int
+	// persists jobs scheduled with SchedulePersistent(), nil => no persistence
+	Store			JobStore
+	// nil => RealClock()
+	Clock			Clock
+	// default number of runs kept in the history of every job
+	History			int
+	// number of finished jobs whose history is kept
+	Retain			int
+	// number of finished workflow runs that are kept (see WorkflowRun()), 0 => 100
+	RetainWorkflows	int
+	// lateness after which a run counts as missed (see MisfirePolicy), 0 => 1s
+	MisfireThreshold	time.Duration
+	// shared lock of instances, singleton jobs only run on the instance holding it, nil => no lock
+	Lock			LockBackend
+	// "" => "scheduler"
+	LockKey			string
+	// lease duration, renewed every LockTTL / 3, 0 => 30s
+	LockTTL			time.Duration
+	// owner of the lock, generated if empty
+	Instance		string
+	// upper bounds of the duration and lateness histograms (see Metrics()), nil => 1ms to 10m
+	MetricsBuckets	[]time.Duration
+	// difference between the expected and actual firing time that is reported as clock jump (see OnClockJump()),
+	// 0 => no detection
+	ClockJumpThreshold	time.Duration
+}
+
 type Job struct {
 	id     	string
 	runAt  	time.Time
-	fn     	func()
+	fn     	JobFunc
 	repeat 	RepeatPolicy
+	timeout	time.Duration
+	overlap	OverlapPolicy
+	handler	string
+	retry	RetryPolicy
+	misfire	MisfirePolicy
+	singleton	bool
+	history	[]RunRecord
+	historySize	int
+	tags	[]string
+	group	string
+	bind	func() JobFunc
+	done	func(err error)
+	limiter	any
+	ctx		context.Context
+	cancel	context.CancelFunc
+	// in-flight (or pending) runs
+	runs	[]*jobRun
+	queued	bool
+	paused	bool
 	index  	int
 }
 
+// Computes the next run after the previous one, a zero time stops repeating
 type RepeatPolicy interface {
     Next(after time.Time) time.Time
 }
@@ -23,27 +108,162 @@ type Scheduler struct {
 	mutex   sync.Mutex
 	jobs  	jobHeap
 	indexMap 	map[string]*Job
-	timer 	*time.Timer
+	// jobs with in-flight runs
+	active		map[string]*Job
+	// runs waiting for a free worker
+	pending		[]*jobRun
+	workers		int
+	// runs that gave up their worker while waiting for a workflow run
+	waiting		int
+	options		Options
+	handlers	map[string]Handler
+	clock		Clock
+	timer 		Timer
+	// runAt the timer was set for
+	timerAt		time.Time
+	// time the timer was set at
+	timerSet	time.Time
+	// end of the lease of Options.Lock (zero if not held)
+	leaseUntil	time.Time
+	lockTimer	Timer
+	// context of Run(), nil if the scheduler isn't running
+	ctx			context.Context
+	// shutting down (see Shutdown()), no new runs are started
+	closed		bool
+	// closed by Shutdown() to return from Run()
+	shutdown	chan struct{}
+	// runs waiting for the delay of a retry
+	retrying	int
+	// jobs whose retries were dropped by Shutdown()
+	dropped		[]string
+	paused		bool
+	idle		*sync.Cond
+	// history of finished jobs
+	finished		map[string][]RunRecord
+	finishedOrder	[]string
+	debouncers		map[string]*debouncer
+	throttlers		map[string]*throttler
+	// secondary indexes (tag / group => ID => job)
+	tags		map[string]map[string]*Job
+	groups		map[string]map[string]*Job
+	// workflow runs by ID
+	workflowRuns	map[string]*workflowRun
+	workflowOrder	[]string
+	metrics		Metrics
+	resultFunc	func(id string, err error)
+	jumpFunc	func(expected time.Time, actual time.Time)
+}
+
+func DefaultOptions() Options {
+	return Options{
+		MaxConcurrency: 0,
+		History: 10,
+		Retain: 100,
+		RetainWorkflows: 100,
+		MisfireThreshold: time.Second,
+		ClockJumpThreshold: time.Minute,
+		LockKey: "scheduler",
+		LockTTL: 30 * time.Second,
+	}
 }
 
 func New() *Scheduler {
-	return &Scheduler{
+	return NewWith(DefaultOptions())
+}
+
+func NewWith(options Options) *Scheduler {
+	if options.Clock == nil {
+		options.Clock = RealClock()
+	}
+
+	if options.Instance == "" {
+		options.Instance = newID()
+	}
+
+	if options.MetricsBuckets == nil {
+		options.MetricsBuckets = defaultBuckets
+	}
+
+	if options.MisfireThreshold <= 0 {
+		options.MisfireThreshold = DefaultOptions().MisfireThreshold
+	}
+
+	if options.RetainWorkflows <= 0 {
+		options.RetainWorkflows = DefaultOptions().RetainWorkflows
+	}
+
+	if options.LockKey == "" {
+		options.LockKey = DefaultOptions().LockKey
+	}
+
+	if options.LockTTL <= 0 {
+		options.LockTTL = DefaultOptions().LockTTL
+	}
+
+	buckets := slices.Clone(options.MetricsBuckets)
+	slices.Sort(buckets)
+
+	scheduler := &Scheduler{
 		jobs:  jobHeap{},
 		indexMap: make(map[string]*Job),
-		timer: time.NewTimer(time.Hour),
+		active: make(map[string]*Job),
+		options: options,
+		handlers: make(map[string]Handler),
+		clock: options.Clock,
+		finished: make(map[string][]RunRecord),
+		debouncers: make(map[string]*debouncer),
+		throttlers: make(map[string]*throttler),
+		tags: make(map[string]map[string]*Job),
+		groups: make(map[string]map[string]*Job),
+		workflowRuns: make(map[string]*workflowRun),
+		metrics: Metrics{
+			Durations: newHistogram(buckets),
+			Lateness: newHistogram(buckets),
+		},
 	}
+
+	scheduler.idle = sync.NewCond(&scheduler.mutex)
+
+	return scheduler
+}
+
+// Set result hook, called after every run with the error returned by the job (nil on success)
+func (scheduler *Scheduler) OnResult(resultFunc func(id string, err error)) {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	scheduler.resultFunc = resultFunc
 }
 
 func (scheduler *Scheduler) Len() int {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
 	return scheduler.jobs.Len()
 }
 
+// Schedule context-aware job at runAt, returns the job ID
+func (scheduler *Scheduler) Schedule(runAt time.Time, fn JobFunc, options JobOptions) string {
+	if options.ID == "" {
+		options.ID = newID()
+	}
+
+	scheduler.addJob(runAt, fn, options)
+
+	return options.ID
+}
+
+// Schedule context-aware job after duration (see Schedule())
+func (scheduler *Scheduler) ScheduleAfter(duration time.Duration, fn JobFunc, options JobOptions) string {
+	return scheduler.Schedule(scheduler.clock.Now().Add(duration), fn, options)
+}
+
 func (scheduler *Scheduler) AddAt(tm time.Time, fn func()) string {
 	return scheduler.add(tm, fn, nil)
 }
 
 func (scheduler *Scheduler) AddAfter(duration time.Duration, fn func()) string {
-	return scheduler.AddAt(time.Now().Add(duration), fn)
+	return scheduler.AddAt(scheduler.clock.Now().Add(duration), fn)
 }
 
 func (scheduler *Scheduler) AddAtWithID(id string, tm time.Time, fn func()) {
@@ -51,39 +271,211 @@ func (scheduler *Scheduler) AddAtWithID(id string, tm time.Time, fn func()) {
 }
 
 func (scheduler *Scheduler) AddAfterWithID(id string, duration time.Duration, fn func()) {
-	scheduler.AddAtWithID(id, time.Now().Add(duration), fn)
+	scheduler.AddAtWithID(id, scheduler.clock.Now().Add(duration), fn)
+}
+
+// Add job that runs at tm and then repeats according to repeat
+func (scheduler *Scheduler) AddRepeating(tm time.Time, repeat RepeatPolicy, fn func()) string {
+	return scheduler.add(tm, fn, repeat)
+}
+
+func (scheduler *Scheduler) AddRepeatingWithID(id string, tm time.Time, repeat RepeatPolicy, fn func()) {
+	scheduler.addWithID(id, tm, fn, repeat)
 }
 
+// Add job that runs every interval, starting one interval from now
+func (scheduler *Scheduler) Every(interval time.Duration, fn func()) string {
+	return scheduler.AddRepeating(scheduler.clock.Now().Add(interval), Interval(interval), fn)
+}
+
+func (scheduler *Scheduler) EveryWithID(id string, interval time.Duration, fn func()) {
+	scheduler.AddRepeatingWithID(id, scheduler.clock.Now().Add(interval), Interval(interval), fn)
+}
+
+// Add job that runs according to cron expression in location (see ParseCron())
+func (scheduler *Scheduler) AddCron(expression string, location *time.Location, fn func()) (string, error) {
+	id := newID()
+
+	err := scheduler.AddCronWithID(id, expression, location, fn)
+
+	if err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+func (scheduler *Scheduler) AddCronWithID(id string, expression string, location *time.Location, fn func()) error {
+	policy, err := ParseCron(expression, location)
+
+	if err != nil {
+		return err
+	}
+
+	next := policy.Next(scheduler.clock.Now())
+
+	if next.IsZero() {
+		return errors.New("cron expression " + expression + " never matches")
+	}
+
+	scheduler.AddRepeatingWithID(id, next, policy, fn)
+
+	return nil
+}
+
+// Fire due jobs until ctx is done or Shutdown() is called, in-flight jobs are cancelled with ctx
 func (scheduler *Scheduler) Run(ctx context.Context) {
-	for {
-		select {
-		case <-scheduler.timer.C:
-			scheduler.fire()
-		case <-ctx.Done():
-			scheduler.timer.Stop()
-			return
-		}
+	scheduler.Start(ctx)
+
+	scheduler.mutex.Lock()
+	shutdown := scheduler.shutdown
+	scheduler.mutex.Unlock()
+
+	select {
+	case <-ctx.Done():
+	case <-shutdown:
 	}
+
+	scheduler.stop(ctx)
 }
 
-func (scheduler *Scheduler) Cancel(id string) bool {
+// Fire due jobs in the background until ctx is done (see Run())
+func (scheduler *Scheduler) Start(ctx context.Context) {
+	scheduler.mutex.Lock()
+	scheduler.ctx = ctx
+	scheduler.closed = false
+	scheduler.shutdown = make(chan struct{})
+	scheduler.dropped = nil
+	scheduler.mutex.Unlock()
+
+	// acquire lock before firing singleton jobs
+	scheduler.renewLease(ctx)
+
+	scheduler.mutex.Lock()
+	scheduler.resetTimerLocked()
+	scheduler.mutex.Unlock()
+
+	context.AfterFunc(ctx, func() {
+		scheduler.stop(ctx)
+	})
+}
+
+func (scheduler *Scheduler) stop(ctx context.Context) {
+	scheduler.mutex.Lock()
+
+	if scheduler.ctx != ctx {
+		scheduler.mutex.Unlock()
+		return
+	}
+
+	scheduler.ctx = nil
+	scheduler.resetTimerLocked()
+
+	release := scheduler.releaseLeaseLocked()
+
+	scheduler.mutex.Unlock()
+
+	if release != nil {
+		release()
+	}
+}
+
+// Block until no runs are in-flight or waiting for a worker,
+// runs waiting for a retry are only waited for while shutting down (see Shutdown())
+func (scheduler *Scheduler) Wait() {
 	scheduler.mutex.Lock()
 	defer scheduler.mutex.Unlock()
 
-	job, ok := scheduler.indexMap[id]
+	for scheduler.busyLocked() {
+		scheduler.idle.Wait()
+	}
+}
+
+// Expects scheduler.mutex to be held
+func (scheduler *Scheduler) busyLocked() bool {
+	return scheduler.workers > 0 || scheduler.waiting > 0 || len(scheduler.pending) > 0 || (scheduler.closed && scheduler.retrying > 0)
+}
+
+func (scheduler *Scheduler) Cancel(id string) bool {
+	scheduler.mutex.Lock()
+
+	job, ok := scheduler.cancelLocked(id)
+
+	scheduler.mutex.Unlock()
+
 	if !ok {
 		return false
 	}
 
-	// remove job from heap
-	heap.Remove(&scheduler.jobs, job.index)
-	delete(scheduler.indexMap, id)
-	
-	scheduler.resetTimerLocked()
+	scheduler.forgetCancelled(job)
 
 	return true
 }
 
+// Unschedule job and stop its in-flight runs, expects scheduler.mutex to be held
+func (scheduler *Scheduler) cancelLocked(id string) (*Job, bool) {
+	job, scheduled := scheduler.indexMap[id]
+	activeJob, active := scheduler.active[id]
+
+	if !scheduled && !active {
+		return nil, false
+	}
+
+	if scheduled {
+		// remove job from heap (paused jobs aren't in the heap)
+		if job.index >= 0 {
+			heap.Remove(&scheduler.jobs, job.index)
+		}
+
+		delete(scheduler.indexMap, id)
+
+		job.cancel()
+
+		scheduler.resetTimerLocked()
+
+		// jobs with in-flight runs leave once they finish
+		if !active {
+			scheduler.leaveLocked(job)
+
+			if job.done != nil {
+				job.done(context.Canceled)
+			}
+		}
+	} else {
+		job = activeJob
+	}
+
+	// stop in-flight runs
+	if active {
+		activeJob.cancel()
+	}
+
+	scheduler.dropLimitersLocked(id)
+
+	return job, true
+}
+
+// Remove cancelled persistent jobs from Options.Store, errors are reported to the result hook
+func (scheduler *Scheduler) forgetCancelled(jobs ...*Job) {
+	scheduler.mutex.Lock()
+	resultFunc := scheduler.resultFunc
+	scheduler.mutex.Unlock()
+
+	for _, job := range jobs {
+		err := scheduler.forget(job)
+
+		if err != nil && resultFunc != nil {
+			resultFunc(job.id, err)
+		}
+	}
+}
+
+// Job left the scheduler (not scheduled and no in-flight runs), expects scheduler.mutex to be held
+func (scheduler *Scheduler) leaveLocked(job *Job) {
+	scheduler.retainLocked(job)
+	scheduler.unindexLocked(job)
+}
+
 func (scheduler *Scheduler) Peek() (string, time.Time, bool) {
 	scheduler.mutex.Lock()
 	defer scheduler.mutex.Unlock()
@@ -124,6 +516,16 @@ func (scheduler *Scheduler) Pop() (bool) {
 	job := heap.Pop(&scheduler.jobs).(*Job)
 	delete(scheduler.indexMap, job.id)
 
+	scheduler.dropLimitersLocked(job.id)
+
+	if len(job.runs) == 0 {
+		scheduler.leaveLocked(job)
+
+		if job.done != nil {
+			job.done(context.Canceled)
+		}
+	}
+
 	scheduler.resetTimerLocked()
 	return true
 }
@@ -137,78 +539,207 @@ func (scheduler *Scheduler) add(runAt time.Time, fn func(), repeat RepeatPolicy)
 }
 
 func (scheduler *Scheduler) addWithID(id string, runAt time.Time, fn func(), repeat RepeatPolicy) {
+	scheduler.addJob(runAt, wrapFunc(fn), JobOptions{
+		ID: id,
+		Repeat: repeat,
+	})
+}
+
+func (scheduler *Scheduler) addJob(runAt time.Time, fn JobFunc, options JobOptions) {
 	scheduler.mutex.Lock()
 	defer scheduler.mutex.Unlock()
 
+	scheduler.addJobLocked(runAt, fn, options)
+}
+
+// Expects scheduler.mutex to be held
+func (scheduler *Scheduler) addJobLocked(runAt time.Time, fn JobFunc, options JobOptions) *Job {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	if options.History == 0 {
+		options.History = scheduler.options.History
+	}
+
 	job := &Job{
-		id:     id,
+		id:     options.ID,
 		runAt:  runAt,
 		fn:     fn,
-		repeat: repeat,
+		repeat: options.Repeat,
+		timeout: options.Timeout,
+		overlap: options.Overlap,
+		handler: options.handler,
+		retry: options.Retry,
+		misfire: options.Misfire,
+		singleton: options.Singleton,
+		historySize: options.History,
+		tags: slices.Clone(options.Tags),
+		group: options.Group,
+		bind: options.bind,
+		done: options.done,
+		limiter: options.limiter,
+		ctx:	ctx,
+		cancel:	cancel,
 	}
 
+	scheduler.replaceLocked(job, options.keepRuns)
+
 	heap.Push(&scheduler.jobs, job)
-	scheduler.indexMap[id] = job
+	scheduler.indexMap[job.id] = job
+	scheduler.indexLocked(job)
 	scheduler.resetTimerLocked()
+
+	return job
+}
+
+// Replace job with the same ID: the old job is unscheduled and its in-flight runs are cancelled (unless keepRuns),
+// its history is kept. Expects scheduler.mutex to be held
+func (scheduler *Scheduler) replaceLocked(job *Job, keepRuns bool) {
+	old, ok := scheduler.lookupLocked(job.id)
+
+	if !ok {
+		return
+	}
+
+	if scheduler.indexMap[job.id] == old {
+		if old.index >= 0 {
+			heap.Remove(&scheduler.jobs, old.index)
+		}
+
+		delete(scheduler.indexMap, job.id)
+	}
+
+	if !keepRuns {
+		old.cancel()
+	}
+
+	scheduler.unindexLocked(old)
+
+	job.history = slices.Clone(old.history)
 }
 
-func (scheduler *Scheduler) fire() {
+func (scheduler *Scheduler) fire(at time.Time) {
 	scheduler.mutex.Lock()
 
-	if len(scheduler.jobs) == 0 {
-		scheduler.resetTimerLocked()
+	ctx := scheduler.ctx
 
+	// not running (stale timer) or paused
+	if ctx == nil || ctx.Err() != nil || scheduler.paused {
 		scheduler.mutex.Unlock()
 		return
 	}
 
-	// get top job
-	job := heap.Pop(&scheduler.jobs).(*Job)
-	delete(scheduler.indexMap, job.id)
+	// compare wall times, monotonic readings hide changes of the wall clock
+	now := scheduler.clock.Now().Round(0)
 
-	scheduler.mutex.Unlock()
+	var jumpFunc func()
+
+	// replaced timers may still fire, timers of overdue jobs are expected to fire right away
+	if at.Equal(scheduler.timerAt) {
+		expected := at
 
-	// run jon
-	go job.fn()
+		if scheduler.timerSet.After(expected) {
+			expected = scheduler.timerSet
+		}
 
-	// prepare for repeat
-	if job.repeat != nil {
-		job.runAt = job.repeat.Next(job.runAt)
+		jumpFunc = scheduler.detectJumpLocked(expected, now)
+	}
 
-		scheduler.mutex.Lock()
+	missed := []*Job{}
 
-		// add job back into queue
-		heap.Push(&scheduler.jobs, job)
+	for len(scheduler.jobs) > 0 && !scheduler.jobs[0].runAt.After(now) {
+		job := scheduler.jobs[0]
 
-		// overwrite old job
-		scheduler.indexMap[job.id] = job
+		lateness := now.Sub(job.runAt.Round(0))
 
-		scheduler.resetTimerLocked()
+		scheduler.metrics.Lateness.observe(lateness)
 
-		scheduler.mutex.Unlock()
+		late := lateness > scheduler.options.MisfireThreshold
+
+		if late && job.misfire == MisfireSkip {
+			scheduler.recordLocked(job, RunRecord{
+				Start: job.runAt,
+				End: job.runAt,
+				Outcome: OutcomeMissed,
+			})
+		} else {
+			scheduler.startLocked(ctx, job)
+		}
+
+		var next time.Time
+
+		if job.repeat != nil {
+			if late && job.misfire == MisfireSkip {
+				next = skipPolicy(job.repeat, job.runAt)
+			} else {
+				next = job.repeat.Next(job.runAt)
+			}
+
+			// continue at the next future slot instead of catching up
+			if late && job.misfire != MisfireRunAll {
+				next = skipMissed(job.repeat, next, now)
+			}
+		}
+
+		// no repeat (or policy is done)
+		if next.IsZero() {
+			heap.Pop(&scheduler.jobs)
+			delete(scheduler.indexMap, job.id)
+
+			if len(job.runs) == 0 {
+				scheduler.leaveLocked(job)
+
+				missed = append(missed, job)
+			}
+
+			continue
+		}
+
+		// reschedule in place, so that Cancel() can still find the job
+		job.runAt = next
+		heap.Fix(&scheduler.jobs, job.index)
 	}
-}
 
+	scheduler.resetTimerLocked()
+
+	scheduler.mutex.Unlock()
+
+	// dropped persistent jobs
+	scheduler.forgetCancelled(missed...)
+
+	if jumpFunc != nil {
+		jumpFunc()
+	}
+}
 
 func (scheduler *Scheduler) resetTimerLocked() {
-	if !scheduler.timer.Stop() {
-		select {
-		case <-scheduler.timer.C:
-		default:
-		}
+	if scheduler.timer != nil {
+		scheduler.timer.Stop()
+		scheduler.timer = nil
 	}
 
-	// no jobs left, reset to default
-	if len(scheduler.jobs) == 0 {
-		scheduler.timer.Reset(time.Hour)
+	if len(scheduler.jobs) == 0 || scheduler.ctx == nil || scheduler.ctx.Err() != nil || scheduler.paused {
 		return
 	}
 
 	// set timer to next runAt
 	next := scheduler.jobs[0].runAt
-	scheduler.timer.Reset(time.Until(next))
+	now := scheduler.clock.Now()
+
+	scheduler.timerAt = next
+	scheduler.timerSet = now
+	scheduler.timer = scheduler.clock.AfterFunc(next.Sub(now), func() {
+		scheduler.fire(next)
+	})
+}
+
+func wrapFunc(fn func()) JobFunc {
+	return func(ctx context.Context) error {
+		fn()
+
+		return nil
+	}
 }
 
 func newID() string {
-	return time.Now().Format("20060102150405.000000000")
+	return rand.Text()
 }
\ No newline at end of file
@@ -0,0 +1,119 @@
+package scheduler
+
+import (
+	"context"
+	"slices"
+	"strings"
+)
+
+// Returned by Shutdown() if runs didn't finish
+type ShutdownError struct {
+	// jobs whose in-flight runs had to be cancelled
+	Interrupted		[]string
+	// jobs whose retries were dropped
+	Dropped			[]string
+}
+
+func (err *ShutdownError) Error() string {
+	message := "shutdown"
+
+	if len(err.Interrupted) > 0 {
+		message += " interrupted jobs: " + strings.Join(err.Interrupted, ", ")
+	}
+
+	if len(err.Dropped) > 0 {
+		if len(err.Interrupted) > 0 {
+			message += ","
+		}
+
+		message += " dropped retries of: " + strings.Join(err.Dropped, ", ")
+	}
+
+	return message
+}
+
+// Stop firing jobs and wait for in-flight runs to finish until ctx is done, Run() returns right away.
+// Retries aren't started anymore, remaining runs are cancelled through their job context,
+// both are reported in a *ShutdownError.
+// Cancelled runs aren't waited for, call Wait() afterwards to block until their handlers returned.
+// Cancelling the context passed to Start() / Run() instead cancels in-flight runs right away
+func (scheduler *Scheduler) Shutdown(ctx context.Context) error {
+	scheduler.mutex.Lock()
+
+	scheduler.ctx = nil
+	scheduler.closed = true
+	scheduler.resetTimerLocked()
+
+	if scheduler.shutdown != nil {
+		select {
+		case <-scheduler.shutdown:
+		default:
+			close(scheduler.shutdown)
+		}
+	}
+
+	// drop runs waiting for a retry
+	for id, job := range scheduler.active {
+		for _, run := range job.runs {
+			if run.waiting {
+				run.cancel()
+
+				scheduler.dropped = append(scheduler.dropped, id)
+			}
+		}
+	}
+
+	release := scheduler.releaseLeaseLocked()
+
+	scheduler.mutex.Unlock()
+
+	if release != nil {
+		release()
+	}
+
+	drained := make(chan struct{})
+
+	go func() {
+		scheduler.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+	case <-ctx.Done():
+	}
+
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	interrupted := []string{}
+
+	select {
+	case <-drained:
+	default:
+		for id, job := range scheduler.active {
+			if len(job.runs) == 0 {
+				continue
+			}
+
+			interrupted = append(interrupted, id)
+
+			for _, run := range job.runs {
+				run.cancel()
+			}
+		}
+	}
+
+	dropped := slices.Compact(slices.Sorted(slices.Values(scheduler.dropped)))
+
+	if len(interrupted) == 0 && len(dropped) == 0 {
+		return nil
+	}
+
+	slices.Sort(interrupted)
+
+	return &ShutdownError{
+		Interrupted: interrupted,
+		Dropped: dropped,
+	}
+}
@@ -0,0 +1,342 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Named handler of persistent jobs, payload is the JSON encoded payload passed to SchedulePersistent()
+type Handler func(ctx context.Context, payload json.RawMessage) error
+
+type MisfirePolicy int
+
+// What happens with runs that are missed (late by more than Options.MisfireThreshold),
+// e.g. because the host was suspended or the wall clock jumped
+const (
+	// run missed jobs once right away, repeating jobs continue at the next future slot
+	MisfireRunOnce MisfirePolicy = iota
+	// drop missed runs, repeating jobs continue at the next future slot
+	MisfireSkip
+	// run every missed run of repeating jobs right away
+	MisfireRunAll
+)
+
+// Serializable representation of a persistent job
+type StoredJob struct {
+	ID			string				`json:"id"`
+	Handler		string				`json:"handler"`
+	Payload		json.RawMessage		`json:"payload,omitempty"`
+	RunAt		time.Time			`json:"runAt"`
+	Timeout		time.Duration		`json:"timeout,omitempty"`
+	Misfire		MisfirePolicy		`json:"misfire,omitempty"`
+	Overlap		OverlapPolicy		`json:"overlap,omitempty"`
+	Retry		RetryPolicy			`json:"retry,omitzero"`
+	History		int					`json:"history,omitempty"`
+	Tags		[]string			`json:"tags,omitempty"`
+	Group		string				`json:"group,omitempty"`
+	Singleton	bool				`json:"singleton,omitempty"`
+}
+
+type JobStore interface {
+	Save(job StoredJob) error
+	Delete(id string) error
+	Load() ([]StoredJob, error)
+}
+
+type StoreError struct {
+	ID		string
+	Err		error
+}
+
+func (err *StoreError) Error() string {
+	return "job store " + err.ID + ": " + err.Err.Error()
+}
+
+func (err *StoreError) Unwrap() error {
+	return err.Err
+}
+
+// Register handler for persistent jobs, must happen before Restore()
+func (scheduler *Scheduler) Handle(name string, handler Handler) {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	scheduler.handlers[name] = handler
+}
+
+// Schedule one-off job that is saved in Options.Store and survives restarts (see Restore()),
+// jobs run at least once: a job interrupted by a crash runs again after restoring
+func (scheduler *Scheduler) SchedulePersistent(runAt time.Time, handler string, payload any, options JobOptions) (string, error) {
+	if scheduler.options.Store == nil {
+		return "", errors.New("no job store configured")
+	}
+
+	if options.Repeat != nil {
+		return "", errors.New("persistent jobs can't repeat")
+	}
+
+	if options.ID == "" {
+		options.ID = newID()
+	}
+
+	data, err := json.Marshal(payload)
+
+	if err != nil {
+		return "", err
+	}
+
+	stored := StoredJob{
+		ID: options.ID,
+		Handler: handler,
+		Payload: data,
+		RunAt: runAt,
+		Timeout: options.Timeout,
+		Misfire: options.Misfire,
+		Overlap: options.Overlap,
+		Retry: options.Retry,
+		History: options.History,
+		Tags: options.Tags,
+		Group: options.Group,
+		Singleton: options.Singleton,
+	}
+
+	err = scheduler.schedulePersistent(stored, runAt, options)
+
+	if err != nil {
+		return "", err
+	}
+
+	return options.ID, nil
+}
+
+// Load jobs from Options.Store, missed jobs are handled according to their MisfirePolicy
+func (scheduler *Scheduler) Restore() error {
+	if scheduler.options.Store == nil {
+		return errors.New("no job store configured")
+	}
+
+	jobs, err := scheduler.options.Store.Load()
+
+	if err != nil {
+		return err
+	}
+
+	now := scheduler.clock.Now()
+
+	var errs []error
+
+	for _, stored := range jobs {
+		runAt := stored.RunAt
+
+		if runAt.Before(now) {
+			late := now.Sub(runAt) > scheduler.options.MisfireThreshold
+
+			switch {
+			case late && stored.Misfire == MisfireSkip:
+				err := scheduler.options.Store.Delete(stored.ID)
+
+				if err != nil {
+					errs = append(errs, &StoreError{ ID: stored.ID, Err: err })
+				}
+
+				continue
+			default:
+				runAt = now
+			}
+		}
+
+		err := scheduler.schedulePersistent(stored, runAt, JobOptions{
+			ID: stored.ID,
+			Timeout: stored.Timeout,
+			Misfire: stored.Misfire,
+			Overlap: stored.Overlap,
+			Retry: stored.Retry,
+			History: stored.History,
+			Tags: stored.Tags,
+			Group: stored.Group,
+			Singleton: stored.Singleton,
+		})
+
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (scheduler *Scheduler) schedulePersistent(stored StoredJob, runAt time.Time, options JobOptions) error {
+	scheduler.mutex.Lock()
+	handler, ok := scheduler.handlers[stored.Handler]
+	scheduler.mutex.Unlock()
+
+	if !ok {
+		return errors.New("handler " + stored.Handler + " not registered")
+	}
+
+	err := scheduler.options.Store.Save(stored)
+
+	if err != nil {
+		return &StoreError{ ID: stored.ID, Err: err }
+	}
+
+	options.handler = stored.Handler
+
+	scheduler.addJob(runAt, func(ctx context.Context) error {
+		return handler(ctx, stored.Payload)
+	}, options)
+
+	return nil
+}
+
+// Remove finished or cancelled persistent job from Options.Store
+func (scheduler *Scheduler) forget(job *Job) error {
+	if job.handler == "" || scheduler.options.Store == nil {
+		return nil
+	}
+
+	err := scheduler.options.Store.Delete(job.id)
+
+	if err != nil {
+		return &StoreError{ ID: job.id, Err: err }
+	}
+
+	return nil
+}
+
+// JobStore backed by a JSON file, that is atomically replaced on every change
+type FileStore struct {
+	path		string
+	mutex		sync.Mutex
+}
+
+func NewFileStore(path string) *FileStore {
+	return &FileStore{
+		path: path,
+	}
+}
+
+func (store *FileStore) Save(job StoredJob) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	jobs, err := store.read()
+
+	if err != nil {
+		return err
+	}
+
+	jobs[job.ID] = job
+
+	return store.write(jobs)
+}
+
+func (store *FileStore) Delete(id string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	jobs, err := store.read()
+
+	if err != nil {
+		return err
+	}
+
+	_, exists := jobs[id]
+
+	if !exists {
+		return nil
+	}
+
+	delete(jobs, id)
+
+	return store.write(jobs)
+}
+
+// Load all jobs sorted by RunAt
+func (store *FileStore) Load() ([]StoredJob, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	jobs, err := store.read()
+
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]StoredJob, 0, len(jobs))
+
+	for _, job := range jobs {
+		list = append(list, job)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].RunAt.Before(list[j].RunAt)
+	})
+
+	return list, nil
+}
+
+func (store *FileStore) read() (map[string]StoredJob, error) {
+	jobs := map[string]StoredJob{}
+
+	data, err := os.ReadFile(store.path)
+
+	if errors.Is(err, os.ErrNotExist) {
+		return jobs, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) == 0 {
+		return jobs, nil
+	}
+
+	err = json.Unmarshal(data, &jobs)
+
+	if err != nil {
+		return nil, errors.New(store.path + ": " + err.Error())
+	}
+
+	return jobs, nil
+}
+
+func (store *FileStore) write(jobs map[string]StoredJob) error {
+	data, err := json.MarshalIndent(jobs, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(store.path), filepath.Base(store.path) + ".*.tmp")
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tmp.Write(data)
+
+	if err == nil {
+		err = tmp.Sync()
+	}
+
+	closeErr := tmp.Close()
+
+	if err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), store.path)
+}
@@ -0,0 +1,129 @@
+package scheduler
+
+// Cancel all jobs with tag, returns the number of cancelled jobs
+func (scheduler *Scheduler) CancelByTag(tag string) int {
+	return scheduler.cancelAll(scheduler.tags, tag)
+}
+
+// Cancel all jobs in group, returns the number of cancelled jobs
+func (scheduler *Scheduler) CancelGroup(group string) int {
+	return scheduler.cancelAll(scheduler.groups, group)
+}
+
+// Snapshots of all jobs with tag (see List())
+func (scheduler *Scheduler) ListByTag(tag string) []JobInfo {
+	return scheduler.listAll(scheduler.tags, tag)
+}
+
+// Snapshots of all jobs in group (see List())
+func (scheduler *Scheduler) ListGroup(group string) []JobInfo {
+	return scheduler.listAll(scheduler.groups, group)
+}
+
+// Pause all jobs in group (see Pause()), returns the number of paused jobs
+func (scheduler *Scheduler) PauseGroup(group string) int {
+	return scheduler.eachScheduled(scheduler.groups, group, scheduler.pauseLocked)
+}
+
+// Resume all jobs in group (see Resume()), returns the number of resumed jobs
+func (scheduler *Scheduler) ResumeGroup(group string) int {
+	return scheduler.eachScheduled(scheduler.groups, group, scheduler.resumeLocked)
+}
+
+func (scheduler *Scheduler) cancelAll(index map[string]map[string]*Job, key string) int {
+	scheduler.mutex.Lock()
+
+	cancelled := []*Job{}
+
+	for id := range index[key] {
+		job, ok := scheduler.cancelLocked(id)
+
+		if ok {
+			cancelled = append(cancelled, job)
+		}
+	}
+
+	scheduler.mutex.Unlock()
+
+	scheduler.forgetCancelled(cancelled...)
+
+	return len(cancelled)
+}
+
+func (scheduler *Scheduler) listAll(index map[string]map[string]*Job, key string) []JobInfo {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	jobs := make([]*Job, 0, len(index[key]))
+
+	for _, job := range index[key] {
+		jobs = append(jobs, job)
+	}
+
+	return scheduler.snapshotsLocked(jobs)
+}
+
+func (scheduler *Scheduler) eachScheduled(index map[string]map[string]*Job, key string, fn func(job *Job) bool) int {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	count := 0
+
+	for id, job := range index[key] {
+		if scheduler.indexMap[id] == job && fn(job) {
+			count++
+		}
+	}
+
+	scheduler.resetTimerLocked()
+
+	return count
+}
+
+// Add job to secondary indexes, expects scheduler.mutex to be held
+func (scheduler *Scheduler) indexLocked(job *Job) {
+	for _, tag := range job.tags {
+		addToIndex(scheduler.tags, tag, job)
+	}
+
+	if job.group != "" {
+		addToIndex(scheduler.groups, job.group, job)
+	}
+}
+
+// Remove job from secondary indexes, expects scheduler.mutex to be held
+func (scheduler *Scheduler) unindexLocked(job *Job) {
+	for _, tag := range job.tags {
+		removeFromIndex(scheduler.tags, tag, job)
+	}
+
+	if job.group != "" {
+		removeFromIndex(scheduler.groups, job.group, job)
+	}
+}
+
+func addToIndex(index map[string]map[string]*Job, key string, job *Job) {
+	jobs, ok := index[key]
+
+	if !ok {
+		jobs = make(map[string]*Job)
+		index[key] = jobs
+	}
+
+	jobs[job.id] = job
+}
+
+func removeFromIndex(index map[string]map[string]*Job, key string, job *Job) {
+	jobs := index[key]
+
+	// don't remove jobs that replaced job
+	if jobs[job.id] != job {
+		return
+	}
+
+	delete(jobs, job.id)
+
+	if len(jobs) == 0 {
+		delete(index, key)
+	}
+}
@@ -0,0 +1,537 @@
+package scheduler
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+type StepState string
+
+const (
+	StepPending StepState = "pending"
+	StepRunning StepState = "running"
+	StepSucceeded StepState = "succeeded"
+	StepFailed StepState = "failed"
+	// a step it depends on failed or was skipped
+	StepSkipped StepState = "skipped"
+	StepCancelled StepState = "cancelled"
+)
+
+type Step struct {
+	Name		string
+	// steps that need to succeed before this step runs
+	DependsOn	[]string
+	Fn			JobFunc
+	Timeout		time.Duration
+	Retry		RetryPolicy
+}
+
+type Workflow struct {
+	Name		string
+	steps		[]Step
+}
+
+type StepStatus struct {
+	State		StepState
+	Start		time.Time
+	End			time.Time
+	Err			error
+}
+
+// Immutable snapshot of a workflow run
+type WorkflowRunInfo struct {
+	ID			string
+	Workflow	string
+	Start		time.Time
+	// zero while running
+	End			time.Time
+	Steps		map[string]StepStatus
+}
+
+// Returns true if every step succeeded
+func (info WorkflowRunInfo) Succeeded() bool {
+	for _, step := range info.Steps {
+		if step.State != StepSucceeded {
+			return false
+		}
+	}
+
+	return true
+}
+
+type WorkflowCycleError struct {
+	Chain	[]string
+}
+
+func (err *WorkflowCycleError) Error() string {
+	return "workflow dependency cycle: " + strings.Join(err.Chain, " -> ")
+}
+
+// Returned by jobs of ScheduleWorkflow() if a step didn't succeed, unwraps to the errors of failed steps
+type WorkflowError struct {
+	Run		WorkflowRunInfo
+}
+
+func (err *WorkflowError) Error() string {
+	names := make([]string, 0, len(err.Run.Steps))
+
+	for name, step := range err.Run.Steps {
+		if step.State != StepSucceeded {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	steps := make([]string, len(names))
+
+	for i, name := range names {
+		step := err.Run.Steps[name]
+
+		steps[i] = name + " " + string(step.State)
+
+		if step.Err != nil {
+			steps[i] += ": " + step.Err.Error()
+		}
+	}
+
+	return "workflow run " + err.Run.ID + ": " + strings.Join(steps, ", ")
+}
+
+func (err *WorkflowError) Unwrap() []error {
+	var errs []error
+
+	for _, step := range err.Run.Steps {
+		if step.State != StepSucceeded && step.Err != nil {
+			errs = append(errs, step.Err)
+		}
+	}
+
+	return errs
+}
+
+type workflowRun struct {
+	id			string
+	workflow	*Workflow
+	start		time.Time
+	end			time.Time
+	steps		map[string]*StepStatus
+	// step => steps depending on it
+	dependents	map[string][]string
+	// closed once every step is done
+	done		chan struct{}
+}
+
+// Create workflow, steps are validated for unique names, unknown dependencies and cycles
+func NewWorkflow(name string, steps ...Step) (*Workflow, error) {
+	byName := map[string]Step{}
+
+	for _, step := range steps {
+		if step.Name == "" || step.Fn == nil {
+			return nil, errors.New("workflow " + name + ": steps need a name and fn")
+		}
+
+		_, exists := byName[step.Name]
+
+		if exists {
+			return nil, errors.New("workflow " + name + ": duplicate step " + step.Name)
+		}
+
+		byName[step.Name] = step
+	}
+
+	for _, step := range steps {
+		for _, dependency := range step.DependsOn {
+			_, exists := byName[dependency]
+
+			if !exists {
+				return nil, errors.New("workflow " + name + ": step " + step.Name + " depends on unknown step " + dependency)
+			}
+		}
+	}
+
+	// detect cycles (0 = unvisited, 1 = visiting, 2 = done)
+	visited := map[string]int{}
+	chain := []string{}
+
+	var visit func(name string) error
+
+	visit = func(name string) error {
+		switch visited[name] {
+		case 1:
+			start := slices.Index(chain, name)
+
+			return &WorkflowCycleError{
+				Chain: append(slices.Clone(chain[start:]), name),
+			}
+		case 2:
+			return nil
+		}
+
+		visited[name] = 1
+		chain = append(chain, name)
+
+		for _, dependency := range byName[name].DependsOn {
+			err := visit(dependency)
+
+			if err != nil {
+				return err
+			}
+		}
+
+		chain = chain[:len(chain) - 1]
+		visited[name] = 2
+
+		return nil
+	}
+
+	for _, step := range steps {
+		err := visit(step.Name)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &Workflow{
+		Name: name,
+		steps: slices.Clone(steps),
+	}, nil
+}
+
+// Start workflow run right away, returns the run ID
+func (scheduler *Scheduler) RunWorkflow(workflow *Workflow) string {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	return scheduler.startWorkflowLocked(workflow).id
+}
+
+// Schedule job that starts a workflow run on every firing (options.Repeat can be used for recurring workflows),
+// returns the job ID. Runs of the job last until the workflow run is finished and fail with a *WorkflowError
+// if a step didn't succeed, the workflow run is cancelled if the job run is (cancel, timeout, shutdown)
+func (scheduler *Scheduler) ScheduleWorkflow(runAt time.Time, workflow *Workflow, options JobOptions) string {
+	return scheduler.Schedule(runAt, func(ctx context.Context) error {
+		scheduler.mutex.Lock()
+
+		run := scheduler.startWorkflowLocked(workflow)
+
+		// waiting for steps doesn't take up a worker, steps would never start with MaxConcurrency otherwise
+		scheduler.workers--
+		scheduler.waiting++
+		scheduler.dispatchLocked()
+
+		scheduler.mutex.Unlock()
+
+		select {
+		case <-run.done:
+		case <-ctx.Done():
+			scheduler.CancelWorkflowRun(run.id)
+		}
+
+		scheduler.mutex.Lock()
+		defer scheduler.mutex.Unlock()
+
+		scheduler.waiting--
+		scheduler.workers++
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		info := run.snapshot()
+
+		if !info.Succeeded() {
+			return &WorkflowError{
+				Run: info,
+			}
+		}
+
+		return nil
+	}, options)
+}
+
+// Snapshot of workflow run, finished runs are kept for Options.RetainWorkflows runs
+func (scheduler *Scheduler) WorkflowRun(id string) (WorkflowRunInfo, bool) {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	run, ok := scheduler.workflowRuns[id]
+
+	if !ok {
+		return WorkflowRunInfo{}, false
+	}
+
+	return run.snapshot(), true
+}
+
+// Snapshots of all known runs of workflow, oldest first
+func (scheduler *Scheduler) WorkflowRuns(name string) []WorkflowRunInfo {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	infos := []WorkflowRunInfo{}
+
+	for _, run := range scheduler.workflowRuns {
+		if run.workflow.Name == name {
+			infos = append(infos, run.snapshot())
+		}
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Start.Before(infos[j].Start)
+	})
+
+	return infos
+}
+
+// Cancel running steps of workflow run, steps that didn't start yet are cancelled as well
+func (scheduler *Scheduler) CancelWorkflowRun(id string) bool {
+	scheduler.mutex.Lock()
+
+	run, ok := scheduler.workflowRuns[id]
+
+	if !ok {
+		scheduler.mutex.Unlock()
+		return false
+	}
+
+	now := scheduler.clock.Now()
+
+	for name, step := range run.steps {
+		if step.State != StepPending && step.State != StepRunning {
+			continue
+		}
+
+		// before cancelling, so that the step isn't finished as well
+		step.State = StepCancelled
+		step.End = now
+
+		scheduler.cancelLocked(run.stepJobID(name))
+	}
+
+	scheduler.finishWorkflowLocked(run)
+
+	scheduler.mutex.Unlock()
+
+	return true
+}
+
+// Expects scheduler.mutex to be held
+func (scheduler *Scheduler) startWorkflowLocked(workflow *Workflow) *workflowRun {
+	run := &workflowRun{
+		id: workflow.Name + ":" + newID(),
+		workflow: workflow,
+		start: scheduler.clock.Now(),
+		steps: map[string]*StepStatus{},
+		dependents: map[string][]string{},
+		done: make(chan struct{}),
+	}
+
+	for _, step := range workflow.steps {
+		run.steps[step.Name] = &StepStatus{
+			State: StepPending,
+		}
+
+		for _, dependency := range step.DependsOn {
+			run.dependents[dependency] = append(run.dependents[dependency], step.Name)
+		}
+	}
+
+	scheduler.workflowRuns[run.id] = run
+	scheduler.workflowOrder = append(scheduler.workflowOrder, run.id)
+
+	for _, step := range workflow.steps {
+		if len(step.DependsOn) == 0 {
+			scheduler.startStepLocked(run, step)
+		}
+	}
+
+	scheduler.finishWorkflowLocked(run)
+
+	return run
+}
+
+// Schedule step as job (cancelled while shutting down), expects scheduler.mutex to be held
+func (scheduler *Scheduler) startStepLocked(run *workflowRun, step Step) {
+	if scheduler.closed {
+		scheduler.finishStepLocked(run, step.Name, context.Canceled)
+		return
+	}
+
+	fn := func(ctx context.Context) error {
+		scheduler.mutex.Lock()
+
+		status := run.steps[step.Name]
+
+		if status.State == StepPending {
+			status.State = StepRunning
+			status.Start = scheduler.clock.Now()
+		}
+
+		scheduler.mutex.Unlock()
+
+		return step.Fn(ctx)
+	}
+
+	job := scheduler.addJobLocked(scheduler.clock.Now(), fn, JobOptions{
+		ID: run.stepJobID(step.Name),
+		Timeout: step.Timeout,
+		Retry: step.Retry,
+		Tags: []string{ run.id },
+		// also called if the job is cancelled or removed before it runs
+		done: func(err error) {
+			scheduler.finishStepLocked(run, step.Name, err)
+		},
+	})
+
+	// start right away instead of waiting for the timer (fires once the scheduler is started or resumed otherwise)
+	ctx := scheduler.ctx
+
+	if ctx == nil || ctx.Err() != nil || scheduler.paused {
+		return
+	}
+
+	heap.Remove(&scheduler.jobs, job.index)
+	delete(scheduler.indexMap, job.id)
+
+	scheduler.startLocked(ctx, job)
+	scheduler.resetTimerLocked()
+}
+
+// Update step state and start (or skip) its dependents, expects scheduler.mutex to be held
+func (scheduler *Scheduler) finishStepLocked(run *workflowRun, name string, err error) {
+	status := run.steps[name]
+
+	// already cancelled
+	if status.State != StepPending && status.State != StepRunning {
+		return
+	}
+
+	status.End = scheduler.clock.Now()
+	status.Err = err
+
+	switch {
+	case err == nil:
+		status.State = StepSucceeded
+	case errors.Is(err, context.Canceled):
+		status.State = StepCancelled
+	default:
+		status.State = StepFailed
+	}
+
+	for _, dependent := range run.dependents[name] {
+		if status.State != StepSucceeded {
+			scheduler.skipStepLocked(run, dependent)
+			continue
+		}
+
+		if run.ready(dependent) {
+			scheduler.startStepLocked(run, run.step(dependent))
+		}
+	}
+
+	scheduler.finishWorkflowLocked(run)
+}
+
+// Skip step and its dependents, expects scheduler.mutex to be held
+func (scheduler *Scheduler) skipStepLocked(run *workflowRun, name string) {
+	status := run.steps[name]
+
+	if status.State != StepPending {
+		return
+	}
+
+	status.State = StepSkipped
+	status.End = scheduler.clock.Now()
+
+	for _, dependent := range run.dependents[name] {
+		scheduler.skipStepLocked(run, dependent)
+	}
+}
+
+// Mark run as finished once every step is done, expects scheduler.mutex to be held
+func (scheduler *Scheduler) finishWorkflowLocked(run *workflowRun) {
+	if !run.end.IsZero() {
+		return
+	}
+
+	for _, status := range run.steps {
+		if status.State == StepPending || status.State == StepRunning {
+			return
+		}
+	}
+
+	run.end = scheduler.clock.Now()
+
+	close(run.done)
+
+	// evict oldest finished runs
+	finished := 0
+
+	for _, id := range scheduler.workflowOrder {
+		if !scheduler.workflowRuns[id].end.IsZero() {
+			finished++
+		}
+	}
+
+	for i := 0; i < len(scheduler.workflowOrder) && finished > scheduler.options.RetainWorkflows; {
+		id := scheduler.workflowOrder[i]
+
+		if scheduler.workflowRuns[id].end.IsZero() {
+			i++
+			continue
+		}
+
+		delete(scheduler.workflowRuns, id)
+		scheduler.workflowOrder = slices.Delete(scheduler.workflowOrder, i, i + 1)
+
+		finished--
+	}
+}
+
+func (run *workflowRun) stepJobID(name string) string {
+	return run.id + "/" + name
+}
+
+func (run *workflowRun) step(name string) Step {
+	for _, step := range run.workflow.steps {
+		if step.Name == name {
+			return step
+		}
+	}
+
+	return Step{}
+}
+
+// Returns true if every dependency of step succeeded
+func (run *workflowRun) ready(name string) bool {
+	for _, dependency := range run.step(name).DependsOn {
+		if run.steps[dependency].State != StepSucceeded {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (run *workflowRun) snapshot() WorkflowRunInfo {
+	info := WorkflowRunInfo{
+		ID: run.id,
+		Workflow: run.workflow.Name,
+		Start: run.start,
+		End: run.end,
+		Steps: make(map[string]StepStatus, len(run.steps)),
+	}
+
+	for name, status := range run.steps {
+		info.Steps[name] = *status
+	}
+
+	return info
+}
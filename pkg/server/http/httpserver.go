@@ -30,6 +30,28 @@ func Create(handler http.Handler, host string, ports ...string) *HttpServer {
 	}
 }
 
+// Mount handler under prefix (for example `/scheduler`), other requests are still served by Handler.
+// Needs to be called before ListenAndServer()
+func (server *HttpServer) Mount(prefix string, handler http.Handler) error {
+	prefix = "/" + strings.Trim(prefix, "/")
+
+	if prefix == "/" {
+		return errors.New("cannot mount at root, use Handler instead")
+	}
+
+	mux := http.NewServeMux()
+
+	if server.Handler != nil {
+		mux.Handle("/", server.Handler)
+	}
+
+	mux.Handle(prefix + "/", http.StripPrefix(prefix, handler))
+
+	server.Handler = mux
+
+	return nil
+}
+
 func (server *HttpServer) ListenAndServer() {
     var wg sync.WaitGroup
     stopCh := make(chan struct{})
@@ -1,12 +1,22 @@
 package tests
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/codeshelldev/gotl/pkg/configutils"
+	types "github.com/codeshelldev/gotl/pkg/configutils/types"
 	"github.com/codeshelldev/gotl/pkg/jsonutils"
+	"gopkg.in/yaml.v3"
 )
 
 func TestConfigUnflattening(t *testing.T) {
@@ -240,4 +250,834 @@ func TestTransform(t *testing.T) {
 	if transformedJson != expectedJson {
 		t.Error("Expected: ", expectedJson, "\nGot: ", transformedJson)
 	}
-}
\ No newline at end of file
+}
+
+type Test_ReferenceSchema struct {
+	Server				Test_ReferenceServer		`koanf:"server"`
+	LogLevel			string						`koanf:"loglevel"     aliases:".level"       description:"Log level"`
+	Timeout				types.Opt[int]				`koanf:"timeout"      description:"Request timeout"`
+	Legacy				bool						`koanf:"legacy"       deprecated:"use server instead"`
+}
+
+type Test_ReferenceServer struct {
+	Port				int							`koanf:"port"         aliases:"p"            description:"Port to listen on"`
+}
+
+func TestConfigReference(t *testing.T) {
+	reference := configutils.BuildReference("", &Test_ReferenceSchema{
+		LogLevel: "info",
+		Server: Test_ReferenceServer{
+			Port: 8080,
+		},
+	})
+
+	expected := configutils.Reference{
+		{
+			Path: "legacy",
+			Type: "bool",
+			Env: "LEGACY",
+			Deprecated: true,
+			DeprecationNote: "use server instead",
+		},
+		{
+			Path: "loglevel",
+			Type: "string",
+			Default: "info",
+			Aliases: []string{"level"},
+			Env: "LOGLEVEL",
+			Description: "Log level",
+		},
+		{
+			Path: "server.port",
+			Type: "int",
+			Default: 8080,
+			Aliases: []string{"server.p"},
+			Env: "SERVER_PORT",
+			Description: "Port to listen on",
+		},
+		{
+			Path: "timeout",
+			Type: "int",
+			Env: "TIMEOUT",
+			Description: "Request timeout",
+			Optional: true,
+		},
+	}
+
+	referenceJson := jsonutils.Pretty(reference)
+	expectedJson := jsonutils.Pretty(expected)
+
+	if referenceJson != expectedJson {
+		t.Error("Expected: ", expectedJson, "\nGot: ", referenceJson)
+	}
+
+	markdown := reference.Markdown()
+
+	expectedRow := "| `server.port` | `int` | `8080` | `SERVER_PORT` | `server.p` | Port to listen on |"
+
+	if !strings.Contains(markdown, expectedRow) {
+		t.Error("Expected row: ", expectedRow, "\nGot: ", markdown)
+	}
+
+	text := reference.Text()
+
+	if !strings.Contains(text, "timeout (int, optional)") {
+		t.Error("Expected optional timeout in: ", text)
+	}
+}
+
+type Test_ReloadSchema struct {
+	Port				int							`koanf:"port"`
+}
+
+func TestConfigTransactionalReload(t *testing.T) {
+	config := configutils.New()
+
+	config.Load(map[string]any{ "port": 8080 }, "")
+
+	var reloadErr error
+
+	config.OnReloadError(func(err error) {
+		reloadErr = err
+	})
+
+	schema := Test_ReloadSchema{}
+
+	validate := func(s *Test_ReloadSchema) error {
+		if s.Port <= 0 {
+			return errors.New("invalid port")
+		}
+
+		return nil
+	}
+
+	err := configutils.ReloadInto(config, "", &schema, validate, configutils.DataStep(map[string]any{ "port": -1 }, ""))
+
+	if err == nil || reloadErr == nil {
+		t.Error("Expected reload error")
+	}
+
+	if config.Layer.Int("port") != 8080 {
+		t.Error("Expected: ", 8080, "\nGot: ", config.Layer.Int("port"))
+	}
+
+	err = configutils.ReloadInto(config, "", &schema, validate, configutils.DataStep(map[string]any{ "port": 9090 }, ""))
+
+	if err != nil {
+		t.Error("Unexpected reload error: ", err.Error())
+	}
+
+	if config.Layer.Int("port") != 9090 || schema.Port != 9090 {
+		t.Error("Expected: ", 9090, "\nGot: ", config.Layer.Int("port"), schema.Port)
+	}
+}
+
+func TestConfigFileWatching(t *testing.T) {
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "config.json")
+	broken := filepath.Join(dir, "broken.json")
+
+	os.WriteFile(path, []byte(`{ "port": 8080 }`), 0o644)
+	os.WriteFile(broken, []byte(`{ "port": `), 0o644)
+
+	reloads := make(chan string, 16)
+
+	config := configutils.NewWith(".", func(name string) {
+		reloads <- name
+	})
+
+	_, err := config.LoadFile(broken, Test_JsonParser{})
+
+	if err == nil {
+		t.Error("Expected load error")
+	}
+
+	_, err = config.LoadFile(path, Test_JsonParser{})
+
+	if err != nil {
+		t.Fatal("Error loading file: ", err.Error())
+	}
+
+	// manual reloads replace the watcher instead of adding one
+	for range 3 {
+		err = config.Reload(configutils.FileStep(path, Test_JsonParser{}))
+
+		if err != nil {
+			t.Fatal("Unexpected reload error: ", err.Error())
+		}
+	}
+
+	os.WriteFile(broken, []byte(`{ "port": 9090 }`), 0o644)
+	os.WriteFile(path, []byte(`{ "port": 9090 }`), 0o644)
+
+	select {
+	case name := <-reloads:
+		if name != path {
+			t.Error("Expected: ", path, "\nGot: ", name)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected reload after file change")
+	}
+
+	time.Sleep(200 * time.Millisecond)
+
+	if len(reloads) != 0 {
+		t.Error("Expected a single reload, got: ", len(reloads) + 1)
+	}
+}
+
+type Test_SecretSchema struct {
+	Password			string						`koanf:"password"     aliases:"pw"`
+}
+
+func TestConfigEncryptedValues(t *testing.T) {
+	oldKey, _ := configutils.GenerateKey()
+	newKey, _ := configutils.GenerateKey()
+
+	oldKeyring, err := configutils.ParseKeys("old:" + oldKey)
+
+	if err != nil {
+		t.Fatal("Error parsing keys: ", err.Error())
+	}
+
+	encrypted, err := oldKeyring.Encrypt("secret")
+
+	if err != nil {
+		t.Fatal("Error encrypting: ", err.Error())
+	}
+
+	// rotated keyring, new key is primary
+	keyring, err := configutils.ParseKeys("new:" + newKey + "\nold:" + oldKey)
+
+	if err != nil {
+		t.Fatal("Error parsing keys: ", err.Error())
+	}
+
+	config := configutils.New()
+
+	config.UseKeyring(keyring)
+
+	err = config.Load(map[string]any{
+		"db": map[string]any{
+			"user": "admin",
+			"password": encrypted,
+		},
+	}, "")
+
+	if err != nil {
+		t.Fatal("Error loading: ", err.Error())
+	}
+
+	if config.Layer.String("db.password") != "secret" {
+		t.Error("Expected: ", "secret", "\nGot: ", config.Layer.String("db.password"))
+	}
+
+	expectedDump := "db.password -> " + configutils.REDACTED + "\ndb.user -> admin\n"
+
+	if config.Sprint() != expectedDump {
+		t.Error("Expected: ", expectedDump, "\nGot: ", config.Sprint())
+	}
+
+	// renamed keys stay secret
+	aliasConfig := configutils.New()
+
+	aliasConfig.UseKeyring(keyring)
+
+	err = aliasConfig.Load(map[string]any{
+		"pw": encrypted,
+	}, "")
+
+	if err != nil {
+		t.Fatal("Error loading encrypted values: ", err.Error())
+	}
+
+	err = aliasConfig.ApplyTransformFuncs("", &Test_SecretSchema{}, "", configutils.TransformOptions{})
+
+	if err != nil {
+		t.Fatal("Error applying transforms: ", err.Error())
+	}
+
+	expectedDump = "password -> " + configutils.REDACTED + "\n"
+
+	if aliasConfig.Sprint() != expectedDump {
+		t.Error("Expected: ", expectedDump, "\nGot: ", aliasConfig.Sprint())
+	}
+
+	if aliasConfig.Provenance("password") != configutils.SOURCE_DATA {
+		t.Error("Expected: ", configutils.SOURCE_DATA, "\nGot: ", aliasConfig.Provenance("password"))
+	}
+
+	dir := t.TempDir()
+
+	os.WriteFile(filepath.Join(dir, "item.json"), []byte(`{ "pw": "` + encrypted + `" }`), 0o644)
+
+	dirConfig := configutils.New()
+
+	dirConfig.UseKeyring(keyring)
+
+	err = dirConfig.LoadDir("items", dir, ".json", Test_JsonParser{}, func(c *configutils.Config, path string) {
+		c.ApplyTransformFuncs("", &Test_SecretSchema{}, "", configutils.TransformOptions{})
+	})
+
+	if err != nil {
+		t.Fatal("Error loading dir: ", err.Error())
+	}
+
+	expectedDump = "items.0.password -> " + configutils.REDACTED + "\n"
+
+	if dirConfig.Sprint() != expectedDump {
+		t.Error("Expected: ", expectedDump, "\nGot: ", dirConfig.Sprint())
+	}
+
+	rotated, err := keyring.Rotate(encrypted)
+
+	if err != nil || !strings.HasPrefix(rotated, configutils.ENCRYPTED_PREFIX + "new:") {
+		t.Error("Expected value encrypted with new key, got: ", rotated, err)
+	}
+}
+
+func TestConfigDotEnv(t *testing.T) {
+	content := `
+# comment
+export HOST=localhost
+PORT = 8080 # inline comment
+URL="http://${HOST}:${PORT}"
+SINGLE='no ${HOST} \n expansion'
+ESCAPED="line1\nline2 \"quoted\""
+MULTI="first
+second"
+FALLBACK=${MISSING:-default}
+`
+
+	vars, err := configutils.ParseDotEnv(content, nil)
+
+	if err != nil {
+		t.Fatal("Error parsing: ", err.Error())
+	}
+
+	expected := map[string]string{
+		"HOST": "localhost",
+		"PORT": "8080",
+		"URL": "http://localhost:8080",
+		"SINGLE": "no ${HOST} \\n expansion",
+		"ESCAPED": "line1\nline2 \"quoted\"",
+		"MULTI": "first\nsecond",
+		"FALLBACK": "default",
+	}
+
+	varsJson := jsonutils.Pretty(vars)
+	expectedJson := jsonutils.Pretty(expected)
+
+	if varsJson != expectedJson {
+		t.Error("Expected: ", expectedJson, "\nGot: ", varsJson)
+	}
+
+	path := filepath.Join(t.TempDir(), ".env")
+
+	os.WriteFile(path, []byte("SERVER_HOST=localhost\nSERVER_PORT=8080\n"), 0o644)
+
+	config := configutils.New()
+
+	_, err = config.LoadDotEnv(path, func(key, value string) (string, any) {
+		return strings.ToLower(strings.ReplaceAll(key, "_", ".")), value
+	})
+
+	if err != nil {
+		t.Fatal("Error loading: ", err.Error())
+	}
+
+	if config.Layer.String("server.host") != "localhost" || config.Layer.Int("server.port") != 8080 {
+		t.Error("Expected: localhost:8080\nGot: ", config.Layer.Sprint())
+	}
+}
+
+func TestConfigTemplateReferences(t *testing.T) {
+	config := configutils.New()
+
+	config.Load(map[string]any{
+		"url": "http://${{ .config.server.address }}/api",
+		"server": map[string]any{
+			"address": "${{ .config.server.host }}:${{ .config.server.port }}",
+			"host": "${{ .vars.host }}",
+			"port": 8080,
+		},
+	}, "")
+
+	err := config.TemplateConfig(map[string]any{
+		"host": "localhost",
+	})
+
+	if err != nil {
+		t.Fatal("Error templating: ", err.Error())
+	}
+
+	expected := "http://localhost:8080/api"
+
+	if config.Layer.String("url") != expected {
+		t.Error("Expected: ", expected, "\nGot: ", config.Layer.String("url"))
+	}
+
+	cyclic := configutils.New()
+
+	cyclic.Load(map[string]any{
+		"a": "${{ .config.b }}",
+		"b": "${{ .config.c }}",
+		"c": "${{ .config.a }}",
+	}, "")
+
+	err = cyclic.TemplateConfig(nil)
+
+	var cycleErr *configutils.ReferenceCycleError
+
+	if !errors.As(err, &cycleErr) {
+		t.Fatal("Expected reference cycle error, got: ", err)
+	}
+
+	expectedChain := "a -> b -> c -> a"
+
+	if strings.Join(cycleErr.Chain, " -> ") != expectedChain {
+		t.Error("Expected: ", expectedChain, "\nGot: ", strings.Join(cycleErr.Chain, " -> "))
+	}
+}
+
+type Test_FieldSchema struct {
+	Test_FieldEmbedded
+	Server				*Test_FieldServer				`koanf:"server"`
+}
+
+type Test_FieldEmbedded struct {
+	Name				string							`koanf:"name"`
+}
+
+type Test_FieldServer struct {
+	TLS					types.Opt[Test_FieldTLS]		`koanf:"tls"`
+}
+
+type Test_FieldTLS struct {
+	Cert				string							`koanf:"cert"         description:"Certificate path"`
+	Key					string							`koanf:"key"`
+}
+
+func TestConfigFieldResolution(t *testing.T) {
+	config := configutils.New()
+
+	path := filepath.Join(t.TempDir(), ".env")
+
+	os.WriteFile(path, []byte("SERVER_TLS_CERT=cert.pem\nNAME=test\n"), 0o644)
+
+	config.LoadDotEnv(path, func(key, value string) (string, any) {
+		return strings.ToLower(strings.ReplaceAll(key, "_", ".")), value
+	})
+
+	schema := Test_FieldSchema{}
+
+	err := config.Unmarshal("", &schema)
+
+	if err != nil {
+		t.Fatal("Error unmarshalling: ", err.Error())
+	}
+
+	info, ok := config.ResolveField(&schema, &schema.Server.TLS.Value.Cert)
+
+	if !ok {
+		t.Fatal("Expected field to be resolved")
+	}
+
+	if info.Path != "server.tls.cert" || info.Field.Tag.Get("description") != "Certificate path" || info.Provenance != "dotenv:" + path {
+		t.Error("Expected: server.tls.cert (Certificate path) from dotenv:", path, "\nGot: ", info.Path, " (", info.Field.Tag.Get("description"), ") from ", info.Provenance)
+	}
+
+	info, ok = configutils.ResolveFieldPointer(&schema, &schema.Name)
+
+	if !ok || info.Path != "name" {
+		t.Error("Expected: name\nGot: ", info.Path)
+	}
+
+	tls := schema.Server.TLS.Value
+
+	if tls.Key == "" {
+		err = config.FieldError(&schema, &tls.Cert, "requires key")
+	}
+
+	expectedErr := "server.tls.cert (from dotenv:" + path + "): requires key"
+
+	if err == nil || err.Error() != expectedErr {
+		t.Error("Expected: ", expectedErr, "\nGot: ", err)
+	}
+}
+
+func TestConfigConcurrency(t *testing.T) {
+	config := configutils.New()
+
+	config.Load(map[string]any{
+		"server": map[string]any{
+			"port": 8080,
+		},
+	}, "")
+
+	options := configutils.TransformOptions{
+		Transforms: map[string]func(string, any) (string, any){
+			"default": func(s string, a any) (string, any) {
+				return s, a
+			},
+		},
+	}
+
+	var wg sync.WaitGroup
+
+	for i := range 4 {
+		wg.Go(func() {
+			for j := range 50 {
+				config.ApplyTransformFuncs("", &Test_ReloadSchema{}, "", options)
+				config.Load(map[string]any{ "counter": i * j }, "")
+				config.TemplateConfig(nil)
+			}
+		})
+
+		wg.Go(func() {
+			for range 50 {
+				// readers never see an empty layer mid-transform
+				if !config.Exists("server.port") {
+					t.Error("Expected server.port to exist")
+				}
+
+				schema := map[string]any{}
+
+				config.Unmarshal("", &schema)
+				config.Sprint()
+				config.Provenance("server.port")
+			}
+		})
+	}
+
+	wg.Wait()
+
+	// transform funcs writing to the Config don't retry forever
+	onUse := map[string]int{}
+
+	writing := configutils.TransformOptions{
+		Transforms: map[string]func(string, any) (string, any){
+			"default": func(s string, a any) (string, any) {
+				config.Load(map[string]any{ "counter": 0 }, "")
+
+				return s, a
+			},
+		},
+		OnUse: map[string]func(string, configutils.TransformTarget){
+			"default": func(source string, target configutils.TransformTarget) {
+				onUse[target.OutputKey]++
+			},
+		},
+	}
+
+	err := config.ApplyTransformFuncs("", &Test_ReloadSchema{}, "", writing)
+
+	if err == nil {
+		t.Error("Expected error for Config changed by transform funcs")
+	}
+
+	// OnUse funcs run on the first attempt only
+	for key, calls := range onUse {
+		if calls != 1 {
+			t.Error("Expected a single OnUse call for ", key, "\nGot: ", calls)
+		}
+	}
+}
+
+type Test_JsonParser struct{}
+
+func (Test_JsonParser) Unmarshal(data []byte) (map[string]any, error) {
+	out := map[string]any{}
+
+	err := json.Unmarshal(data, &out)
+
+	return out, err
+}
+
+func (Test_JsonParser) Marshal(data map[string]any) ([]byte, error) {
+	return json.Marshal(data)
+}
+
+type Test_TenantSchema struct {
+	Name				string							`koanf:"name"`
+	Region				string							`koanf:"region"`
+	Limit				int								`koanf:"limit"`
+}
+
+func TestConfigSet(t *testing.T) {
+	dir := t.TempDir()
+
+	os.WriteFile(filepath.Join(dir, "premium.json"), []byte(`{ "limit": 100 }`), 0o644)
+	os.WriteFile(filepath.Join(dir, "acme.json"), []byte(`{ "extends": "premium", "name": "acme" }`), 0o644)
+	os.WriteFile(filepath.Join(dir, "small.json"), []byte(`{ "name": "small", "limit": "invalid" }`), 0o644)
+
+	base := configutils.New()
+
+	base.Load(map[string]any{
+		"region": "eu",
+		"limit": 10,
+	}, "")
+
+	set := configutils.NewSet(base)
+
+	err := set.LoadDir(dir, ".json", Test_JsonParser{}, func(c *configutils.Config, path string) {})
+
+	if err != nil {
+		t.Fatal("Error loading: ", err.Error())
+	}
+
+	chain, _ := set.Chain("acme")
+
+	if strings.Join(chain, ",") != "premium,acme" {
+		t.Error("Expected: premium,acme\nGot: ", chain)
+	}
+
+	set.SetOverride("acme", map[string]any{ "region": "us" }, "")
+
+	tenants, err := configutils.UnmarshalSet[Test_TenantSchema](set, "")
+
+	var tenantErr *configutils.TenantError
+
+	if !errors.As(err, &tenantErr) || tenantErr.Tenant != "small" {
+		t.Error("Expected error for tenant small, got: ", err)
+	}
+
+	expected := map[string]Test_TenantSchema{
+		"acme": { Name: "acme", Region: "us", Limit: 100 },
+		"premium": { Region: "eu", Limit: 100 },
+	}
+
+	tenantsJson := jsonutils.Pretty(tenants)
+	expectedJson := jsonutils.Pretty(expected)
+
+	if tenantsJson != expectedJson {
+		t.Error("Expected: ", expectedJson, "\nGot: ", tenantsJson)
+	}
+
+	base.MarkSecret("region")
+
+	resolved, err := set.Resolve("acme")
+
+	if err != nil {
+		t.Fatal("Error resolving: ", err.Error())
+	}
+
+	if resolved.Exists("extends") {
+		t.Error("Expected extends to be removed, got: ", resolved.Get("extends"))
+	}
+
+	if !resolved.IsSecret("region") {
+		t.Error("Expected region to be secret")
+	}
+}
+
+type Test_OptSchema struct {
+	Name		types.Opt[string]	`json:"name,omitzero"`
+	Port		types.Opt[int]		`json:"port,omitzero"`
+	Tags		types.Opt[[]string]	`json:"tags,omitzero"`
+}
+
+func TestConfigOptJson(t *testing.T) {
+	var schema Test_OptSchema
+
+	err := json.Unmarshal([]byte(`{ "name": "api", "port": null }`), &schema)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !schema.Name.HasValue() || *schema.Name.Value != "api" {
+		t.Error("expected name to be set to api, got:", schema.Name)
+	}
+
+	if !schema.Port.IsNull() {
+		t.Error("expected port to be explicit null, got:", schema.Port)
+	}
+
+	if schema.Tags.Set {
+		t.Error("expected tags to be absent, got:", schema.Tags)
+	}
+
+	data, err := json.Marshal(schema)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"name":"api","port":null}`
+
+	if string(data) != expected {
+		t.Error("\nExpected: ", expected, "\nGot: ", string(data))
+	}
+
+	var roundTrip Test_OptSchema
+
+	err = json.Unmarshal(data, &roundTrip)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(roundTrip, schema) {
+		t.Error("\nExpected: ", schema, "\nGot: ", roundTrip)
+	}
+
+	err = json.Unmarshal([]byte(`{ "port": "not a number" }`), &roundTrip)
+
+	if err == nil {
+		t.Error("expected error for invalid port")
+	}
+}
+
+type Test_OptYamlSchema struct {
+	Name		types.Opt[string]	`yaml:"name,omitempty"`
+	Port		types.Opt[int]		`yaml:"port,omitempty"`
+	Tags		types.Opt[[]string]	`yaml:"tags,omitempty"`
+}
+
+func TestConfigOptYaml(t *testing.T) {
+	var schema Test_OptYamlSchema
+
+	err := yaml.Unmarshal([]byte("name: api\nport: null\n"), &schema)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !schema.Name.HasValue() || *schema.Name.Value != "api" {
+		t.Error("expected name to be set to api, got:", schema.Name)
+	}
+
+	// yaml decoders skip unmarshalers for null
+	if schema.Port.Set || schema.Tags.Set {
+		t.Error("expected port and tags to be absent, got:", schema.Port, schema.Tags)
+	}
+
+	schema.Port = types.Null[int]()
+	schema.Tags = types.Some([]string{ "a", "b" })
+
+	data, err := yaml.Marshal(schema)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "name: api\nport: null\ntags:\n    - a\n    - b\n"
+
+	if string(data) != expected {
+		t.Error("\nExpected: ", expected, "\nGot: ", string(data))
+	}
+
+	// absent is omitted through IsZero()
+	data, err = yaml.Marshal(Test_OptYamlSchema{ Name: types.Some("api") })
+
+	if err != nil || string(data) != "name: api\n" {
+		t.Error("expected absent values to be omitted, got:", string(data), err)
+	}
+
+	var roundTrip Test_OptYamlSchema
+
+	err = yaml.Unmarshal([]byte("name: api\ntags:\n    - a\n    - b\n"), &roundTrip)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedSchema := Test_OptYamlSchema{
+		Name: types.Some("api"),
+		Tags: types.Some([]string{ "a", "b" }),
+	}
+
+	if !reflect.DeepEqual(roundTrip, expectedSchema) {
+		t.Error("\nExpected: ", expectedSchema, "\nGot: ", roundTrip)
+	}
+
+	err = yaml.Unmarshal([]byte("port: not a number\n"), &roundTrip)
+
+	if err == nil {
+		t.Error("expected error for invalid port")
+	}
+}
+
+func TestConfigOptCombinators(t *testing.T) {
+	absent := types.Opt[int]{}
+	null := types.Null[int]()
+	value := types.Some(8080)
+	other := types.Some(9090)
+
+	if absent.OptOrFallback(value) != 8080 {
+		t.Error("expected absent to fall back, got:", absent.OptOrFallback(value))
+	}
+
+	if null.OptOrFallback(value) != 0 {
+		t.Error("expected null to not fall back, got:", null.OptOrFallback(value))
+	}
+
+	if value.OptOrFallback(other) != 8080 {
+		t.Error("expected value to win, got:", value.OptOrFallback(other))
+	}
+
+	if absent.OptOrFallback(null) != 0 || absent.OptOrFallback(absent) != 0 {
+		t.Error("expected absent and null fallbacks to return 0")
+	}
+
+	// OptOrEmpty is a deprecated alias of OptOrFallback
+	for _, optional := range []types.Opt[int]{ absent, null, value } {
+		for _, fallback := range []types.Opt[int]{ absent, null, other } {
+			if optional.OptOrEmpty(fallback) != optional.OptOrFallback(fallback) {
+				t.Error("expected OptOrEmpty to match OptOrFallback, got:", optional.OptOrEmpty(fallback), optional.OptOrFallback(fallback))
+			}
+		}
+	}
+
+	if absent.Or(value) != value || null.Or(value) != value || value.Or(other) != value {
+		t.Error("expected Or() to return the first Opt with a value")
+	}
+
+	str := types.Map(value, strconv.Itoa)
+
+	if !str.HasValue() || *str.Value != "8080" {
+		t.Error("expected mapped value 8080, got:", str)
+	}
+
+	if !types.Map(null, strconv.Itoa).IsNull() {
+		t.Error("expected mapped null to stay null")
+	}
+
+	if types.Map(absent, strconv.Itoa).Set {
+		t.Error("expected mapped absent to stay absent")
+	}
+
+	got, ok := value.Get()
+
+	if !ok || got != 8080 {
+		t.Error("expected Get() to return 8080, got:", got, ok)
+	}
+
+	_, ok = null.Get()
+
+	if ok {
+		t.Error("expected Get() of null to not be ok")
+	}
+
+	tests := []struct {
+		name		string
+		layers		[]types.Opt[int]
+		expected	types.Opt[int]
+	}{
+		{ "empty", nil, absent },
+		{ "absent keeps lower layer", []types.Opt[int]{ value, absent }, value },
+		{ "value overrides", []types.Opt[int]{ value, other }, other },
+		{ "null clears", []types.Opt[int]{ value, null }, null },
+		{ "value after null", []types.Opt[int]{ value, null, other }, other },
+	}
+
+	for _, test := range tests {
+		merged := types.MergeOpts(test.layers...)
+
+		if merged.Set != test.expected.Set || merged.Value != test.expected.Value {
+			t.Error(test.name, "\nExpected: ", test.expected, "\nGot: ", merged)
+		}
+	}
+}
@@ -0,0 +1,2732 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/codeshelldev/gotl/pkg/scheduler"
+)
+
+func TestSchedulerOrdering(t *testing.T) {
+	s := scheduler.New()
+
+	now := time.Now()
+
+	s.AddAtWithID("c", now.Add(3 * time.Hour), func() {})
+	s.AddAtWithID("a", now.Add(1 * time.Hour), func() {})
+	s.AddAtWithID("d", now.Add(4 * time.Hour), func() {})
+	s.AddAtWithID("b", now.Add(2 * time.Hour), func() {})
+
+	if !s.Cancel("c") {
+		t.Error("expected c to be cancelled")
+	}
+
+	expected := []string{ "a", "b", "d" }
+
+	for _, id := range expected {
+		got, _, ok := s.Peek()
+
+		if !ok || got != id {
+			t.Error("\nExpected: ", id, "\nGot: ", got)
+		}
+
+		s.Pop()
+	}
+
+	if s.Len() != 0 {
+		t.Error("expected empty scheduler, got:", s.Len())
+	}
+}
+
+func TestSchedulerRepeatPolicies(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	interval := scheduler.Interval(time.Minute)
+
+	if !interval.Next(start).Equal(start.Add(time.Minute)) {
+		t.Error("expected interval to add one minute, got:", interval.Next(start))
+	}
+
+	jitter := scheduler.Jitter(time.Minute, 10 * time.Second)
+
+	for range 100 {
+		next := jitter.Next(start)
+
+		if next.Before(start.Add(time.Minute)) || !next.Before(start.Add(time.Minute + 10 * time.Second)) {
+			t.Fatal("jitter out of range:", next.Sub(start))
+		}
+	}
+
+	// jitter is added to the unjittered schedule and doesn't add up
+	next := start
+
+	for i := range 100 {
+		next = jitter.Next(next)
+
+		slot := start.Add(time.Duration(i + 1) * time.Minute)
+
+		if next.Before(slot) || !next.Before(slot.Add(10 * time.Second)) {
+			t.Fatal("jitter drifted from the schedule:", next.Sub(slot))
+		}
+	}
+
+	backoff := scheduler.Backoff(time.Second, 5 * time.Second, 2)
+
+	expected := []time.Duration{ time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second }
+
+	for _, delay := range expected {
+		next := backoff.Next(start)
+
+		if next.Sub(start) != delay {
+			t.Error("\nExpected: ", delay, "\nGot: ", next.Sub(start))
+		}
+	}
+
+	backoff.Reset()
+
+	if backoff.Next(start).Sub(start) != time.Second {
+		t.Error("expected backoff to reset to initial delay")
+	}
+
+	times := scheduler.Times(3, scheduler.Interval(time.Minute))
+
+	if times.Next(start).IsZero() || times.Next(start).IsZero() {
+		t.Error("expected second and third run")
+	}
+
+	if !times.Next(start).IsZero() {
+		t.Error("expected policy to stop after three runs")
+	}
+
+	// skipped slots don't count as runs
+	skipped := scheduler.Times(2, scheduler.Interval(time.Minute))
+
+	for range 5 {
+		if skipped.Skip(start).IsZero() {
+			t.Error("expected skipped slots to not count")
+		}
+	}
+
+	if skipped.Next(start).IsZero() || !skipped.Next(start).IsZero() {
+		t.Error("expected policy to stop after two runs")
+	}
+
+	excluded := scheduler.Exclude(scheduler.Times(2, scheduler.Interval(time.Hour)), scheduler.DailyWindow(time.UTC, time.Hour, 12 * time.Hour))
+
+	if excluded.Next(start).IsZero() || !excluded.Next(start).IsZero() {
+		t.Error("expected runs outside of the calendar to not count")
+	}
+}
+
+func TestSchedulerRepeating(t *testing.T) {
+	clock := scheduler.NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	s := scheduler.NewWith(scheduler.Options{
+		Clock: clock,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s.Start(ctx)
+
+	var every atomic.Int32
+	var limited atomic.Int32
+
+	id := s.Every(time.Minute, func() {
+		every.Add(1)
+	})
+
+	s.AddRepeating(clock.Now(), scheduler.Times(3, scheduler.Interval(time.Minute)), func() {
+		limited.Add(1)
+	})
+
+	clock.Advance(30 * time.Second)
+	s.Wait()
+
+	if every.Load() != 0 || limited.Load() != 1 {
+		t.Error("expected only the first Times() run, got:", every.Load(), limited.Load())
+	}
+
+	clock.Advance(30 * time.Second)
+	s.Wait()
+
+	if every.Load() != 1 || limited.Load() != 2 {
+		t.Error("expected second run, got:", every.Load(), limited.Load())
+	}
+
+	clock.Advance(5 * time.Minute)
+	s.Wait()
+
+	if every.Load() != 6 {
+		t.Error("expected Every() job to run 6 times, got:", every.Load())
+	}
+
+	if limited.Load() != 3 {
+		t.Error("expected Times() job to run exactly 3 times, got:", limited.Load())
+	}
+
+	if s.Len() != 1 {
+		t.Error("expected only the Every() job to be left, got:", s.Len())
+	}
+
+	if !s.Cancel(id) {
+		t.Error("expected Every() job to be cancelled")
+	}
+
+	clock.Advance(time.Hour)
+	s.Wait()
+
+	if every.Load() != 6 {
+		t.Error("expected cancelled job to not run, got:", every.Load())
+	}
+
+	// stopped schedulers don't fire
+	cancel()
+
+	s.AddAfter(time.Second, func() {
+		every.Add(1)
+	})
+
+	clock.Advance(time.Minute)
+	s.Wait()
+
+	if every.Load() != 6 {
+		t.Error("expected stopped scheduler to not fire, got:", every.Load())
+	}
+
+	first := s.AddAfter(time.Hour, func() {})
+	second := s.AddAfter(time.Hour, func() {})
+
+	if first == second {
+		t.Error("expected unique IDs, got:", first, second)
+	}
+}
+
+func TestSchedulerCron(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		expression	string
+		expected	[]time.Time
+	}{
+		{ "*/15 * * * *", []time.Time{
+			time.Date(2026, 1, 1, 0, 15, 0, 0, time.UTC),
+			time.Date(2026, 1, 1, 0, 30, 0, 0, time.UTC),
+		}},
+		{ "30 */20 * * * *", []time.Time{
+			time.Date(2026, 1, 1, 0, 0, 30, 0, time.UTC),
+			time.Date(2026, 1, 1, 0, 20, 30, 0, time.UTC),
+		}},
+		{ "0 9 * * MON-FRI", []time.Time{
+			time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC),
+			time.Date(2026, 1, 2, 9, 0, 0, 0, time.UTC),
+			time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC),
+		}},
+		{ "0 0 1,15 feb,Mar *", []time.Time{
+			time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC),
+			time.Date(2026, 2, 15, 0, 0, 0, 0, time.UTC),
+			time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+		}},
+		// day of month and weekday are OR-ed
+		{ "0 12 13 * 5", []time.Time{
+			time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC),
+			time.Date(2026, 1, 9, 12, 0, 0, 0, time.UTC),
+			time.Date(2026, 1, 13, 12, 0, 0, 0, time.UTC),
+			time.Date(2026, 1, 16, 12, 0, 0, 0, time.UTC),
+		}},
+		{ "0 0 * * 7", []time.Time{
+			time.Date(2026, 1, 4, 0, 0, 0, 0, time.UTC),
+		}},
+		{ "@monthly", []time.Time{
+			time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC),
+			time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+		}},
+		{ "@hourly", []time.Time{
+			time.Date(2026, 1, 1, 1, 0, 0, 0, time.UTC),
+		}},
+		{ "0 0 30 2 *", []time.Time{
+			{},
+		}},
+	}
+
+	for _, test := range tests {
+		policy, err := scheduler.ParseCron(test.expression, time.UTC)
+
+		if err != nil {
+			t.Error(test.expression, err)
+			continue
+		}
+
+		after := start
+
+		for _, expected := range test.expected {
+			next := policy.Next(after)
+
+			if !next.Equal(expected) {
+				t.Error(test.expression, "\nExpected: ", expected, "\nGot: ", next)
+				break
+			}
+
+			after = next
+		}
+	}
+
+	invalid := []string{ "* * * *", "60 * * * *", "* * * 13 *", "5-1 * * * *", "*/0 * * * *", "@fortnightly", "* * * * FOO" }
+
+	for _, expression := range invalid {
+		_, err := scheduler.ParseCron(expression, time.UTC)
+
+		if err == nil {
+			t.Error("expected error for", expression)
+		}
+	}
+}
+
+func TestSchedulerCronTimeZones(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	weekdays, err := scheduler.ParseCron("30 2 * * MON-FRI", berlin)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// friday => monday
+	next := weekdays.Next(time.Date(2026, 1, 9, 3, 0, 0, 0, berlin))
+	expected := time.Date(2026, 1, 12, 1, 30, 0, 0, time.UTC)
+
+	if !next.Equal(expected) {
+		t.Error("\nExpected: ", expected, "\nGot: ", next)
+	}
+
+	daily, err := scheduler.ParseCron("30 2 * * *", berlin)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hourly, err := scheduler.ParseCron("0 * * * *", berlin)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	halfHourly, err := scheduler.ParseCron("*/30 * * * *", berlin)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name		string
+		policy		*scheduler.CronPolicy
+		after		time.Time
+		expected	[]time.Time
+	}{
+		{
+			// 2026-03-29 02:00 CET => 03:00 CEST, 02:30 doesn't exist and runs at the end of the gap
+			"spring forward daily", daily, time.Date(2026, 3, 28, 2, 30, 0, 0, berlin),
+			[]time.Time{
+				time.Date(2026, 3, 29, 1, 0, 0, 0, time.UTC),
+				time.Date(2026, 3, 30, 0, 30, 0, 0, time.UTC),
+			},
+		},
+		{
+			"spring forward hourly", hourly, time.Date(2026, 3, 29, 0, 30, 0, 0, time.UTC),
+			[]time.Time{
+				time.Date(2026, 3, 29, 1, 0, 0, 0, time.UTC),
+				time.Date(2026, 3, 29, 2, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			// 2026-10-25 03:00 CEST => 02:00 CET, 02:30 happens twice but only runs once
+			"fall back daily", daily, time.Date(2026, 10, 24, 2, 30, 0, 0, berlin),
+			[]time.Time{
+				time.Date(2026, 10, 25, 0, 30, 0, 0, time.UTC),
+				time.Date(2026, 10, 26, 1, 30, 0, 0, time.UTC),
+			},
+		},
+		{
+			"fall back from second occurrence", daily, time.Date(2026, 10, 25, 1, 15, 0, 0, time.UTC),
+			[]time.Time{
+				time.Date(2026, 10, 26, 1, 30, 0, 0, time.UTC),
+			},
+		},
+		{
+			// wildcard hours run in both occurrences of 02:00 - 03:00
+			"fall back half hourly", halfHourly, time.Date(2026, 10, 24, 23, 30, 0, 0, time.UTC),
+			[]time.Time{
+				time.Date(2026, 10, 25, 0, 0, 0, 0, time.UTC),
+				time.Date(2026, 10, 25, 0, 30, 0, 0, time.UTC),
+				time.Date(2026, 10, 25, 1, 0, 0, 0, time.UTC),
+				time.Date(2026, 10, 25, 1, 30, 0, 0, time.UTC),
+				time.Date(2026, 10, 25, 2, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			"spring forward half hourly", halfHourly, time.Date(2026, 3, 29, 0, 30, 0, 0, time.UTC),
+			[]time.Time{
+				time.Date(2026, 3, 29, 1, 0, 0, 0, time.UTC),
+				time.Date(2026, 3, 29, 1, 30, 0, 0, time.UTC),
+			},
+		},
+	}
+
+	for _, test := range tests {
+		after := test.after
+
+		for _, expected := range test.expected {
+			next := test.policy.Next(after)
+
+			if !next.Equal(expected) {
+				t.Error(test.name, "\nExpected: ", expected, "\nGot: ", next)
+				break
+			}
+
+			after = next
+		}
+	}
+}
+
+func TestSchedulerJobContext(t *testing.T) {
+	clock := scheduler.NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	options := scheduler.DefaultOptions()
+	options.Clock = clock
+
+	s := scheduler.NewWith(options)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	results := make(chan error, 10)
+
+	s.OnResult(func(id string, err error) {
+		results <- err
+	})
+
+	s.Start(ctx)
+
+	expectResult := func(name string, check func(error) bool) {
+		select {
+		case err := <-results:
+			if !check(err) {
+				t.Error(name, "unexpected result:", err)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal(name, "timed out waiting for result")
+		}
+	}
+
+	failed := errors.New("failed")
+
+	s.Schedule(clock.Now(), func(ctx context.Context) error {
+		return failed
+	}, scheduler.JobOptions{})
+
+	clock.Advance(0)
+
+	expectResult("error", func(err error) bool {
+		return errors.Is(err, failed)
+	})
+
+	// timeouts run on the scheduler clock
+	deadlines := make(chan time.Time, 1)
+
+	s.Schedule(clock.Now(), func(ctx context.Context) error {
+		deadline, _ := ctx.Deadline()
+		deadlines <- deadline
+
+		<-ctx.Done()
+
+		return ctx.Err()
+	}, scheduler.JobOptions{
+		ID: "timeout",
+		Timeout: time.Minute,
+	})
+
+	clock.Advance(0)
+
+	if deadline := <-deadlines; !deadline.Equal(clock.Now().Add(time.Minute)) {
+		t.Error("expected deadline on the scheduler clock, got:", deadline)
+	}
+
+	clock.Advance(59 * time.Second)
+
+	select {
+	case err := <-results:
+		t.Error("expected job to run until its timeout, got:", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	clock.Advance(time.Second)
+
+	expectResult("timeout", func(err error) bool {
+		return errors.Is(err, context.DeadlineExceeded)
+	})
+
+	if history, _ := s.History("timeout"); len(history) != 1 || history[0].Outcome != scheduler.OutcomeTimedOut {
+		t.Error("expected timed out run in history, got:", history)
+	}
+
+	started := make(chan struct{})
+
+	s.Schedule(clock.Now(), func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+
+		return ctx.Err()
+	}, scheduler.JobOptions{
+		ID: "cancelled",
+	})
+
+	clock.Advance(0)
+
+	<-started
+
+	if !s.Cancel("cancelled") {
+		t.Error("expected running job to be cancelled")
+	}
+
+	expectResult("cancel", func(err error) bool {
+		return errors.Is(err, context.Canceled)
+	})
+
+	started = make(chan struct{})
+
+	s.Schedule(clock.Now(), func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+
+		return ctx.Err()
+	}, scheduler.JobOptions{})
+
+	clock.Advance(0)
+
+	<-started
+
+	// stopping the scheduler cancels in-flight jobs
+	cancel()
+
+	expectResult("stop", func(err error) bool {
+		return errors.Is(err, context.Canceled)
+	})
+}
+
+func TestSchedulerWorkerPool(t *testing.T) {
+	clock := scheduler.NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	s := scheduler.NewWith(scheduler.Options{
+		Clock: clock,
+		MaxConcurrency: 2,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s.Start(ctx)
+
+	var running atomic.Int32
+	var maxRunning atomic.Int32
+	var done atomic.Int32
+
+	started := make(chan struct{}, 6)
+	release := make(chan struct{})
+
+	for range 6 {
+		s.Schedule(clock.Now(), func(ctx context.Context) error {
+			current := running.Add(1)
+
+			for {
+				previous := maxRunning.Load()
+
+				if current <= previous || maxRunning.CompareAndSwap(previous, current) {
+					break
+				}
+			}
+
+			started <- struct{}{}
+			<-release
+
+			running.Add(-1)
+			done.Add(1)
+
+			return nil
+		}, scheduler.JobOptions{})
+	}
+
+	clock.Advance(0)
+
+	<-started
+	<-started
+
+	// the others wait for a free worker
+	if done.Load() != 0 || len(started) != 0 {
+		t.Error("expected 2 jobs to run, got:", 2 + len(started))
+	}
+
+	close(release)
+	s.Wait()
+
+	if done.Load() != 6 {
+		t.Error("expected all jobs to finish, got:", done.Load())
+	}
+
+	if maxRunning.Load() != 2 {
+		t.Error("expected at most 2 concurrent jobs, got:", maxRunning.Load())
+	}
+}
+
+func TestSchedulerOverlap(t *testing.T) {
+	clock := scheduler.NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	options := scheduler.DefaultOptions()
+	options.Clock = clock
+
+	s := scheduler.NewWith(options)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s.Start(ctx)
+
+	for _, overlap := range []scheduler.OverlapPolicy{ scheduler.OverlapSkip, scheduler.OverlapQueue } {
+		var starts atomic.Int32
+		var running atomic.Int32
+		var overlapped atomic.Bool
+
+		started := make(chan struct{})
+		release := make(chan struct{})
+
+		id := s.Schedule(clock.Now(), func(ctx context.Context) error {
+			if running.Add(1) > 1 {
+				overlapped.Store(true)
+			}
+
+			defer running.Add(-1)
+
+			if starts.Add(1) == 1 {
+				close(started)
+				<-release
+			}
+
+			return nil
+		}, scheduler.JobOptions{
+			Repeat: scheduler.Interval(5 * time.Millisecond),
+			Overlap: overlap,
+		})
+
+		clock.Advance(0)
+
+		<-started
+
+		// due 6 times while running
+		clock.Advance(30 * time.Millisecond)
+
+		if starts.Load() != 1 {
+			t.Error(overlap, "expected no new runs while running, got:", starts.Load())
+		}
+
+		close(release)
+		s.Wait()
+
+		clock.Advance(5 * time.Millisecond)
+		s.Wait()
+
+		if starts.Load() < 2 {
+			t.Error(overlap, "expected runs to continue, got:", starts.Load())
+		}
+
+		s.Cancel(id)
+
+		if overlapped.Load() {
+			t.Error(overlap, "expected runs to not overlap")
+		}
+	}
+
+	results := make(chan error, 2)
+	started := make(chan struct{}, 2)
+
+	s.Schedule(clock.Now(), func(ctx context.Context) error {
+		started <- struct{}{}
+		<-ctx.Done()
+
+		results <- ctx.Err()
+
+		return ctx.Err()
+	}, scheduler.JobOptions{
+		ID: "replace",
+		Repeat: scheduler.Times(2, scheduler.Interval(10 * time.Millisecond)),
+		Overlap: scheduler.OverlapReplace,
+	})
+
+	clock.Advance(0)
+
+	<-started
+
+	clock.Advance(10 * time.Millisecond)
+
+	select {
+	case err := <-results:
+		if !errors.Is(err, context.Canceled) {
+			t.Error("expected first run to be replaced, got:", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for replaced run")
+	}
+
+	s.Cancel("replace")
+}
+
+func TestSchedulerPersistentJobs(t *testing.T) {
+	store := scheduler.NewFileStore(filepath.Join(t.TempDir(), "jobs.json"))
+
+	options := scheduler.DefaultOptions()
+	options.Store = store
+
+	handler := func(ctx context.Context, payload json.RawMessage) error {
+		return nil
+	}
+
+	before := scheduler.NewWith(options)
+	before.Handle("delete-upload", handler)
+
+	_, err := before.SchedulePersistent(time.Now(), "unknown", nil, scheduler.JobOptions{})
+
+	if err == nil {
+		t.Error("expected error for unregistered handler")
+	}
+
+	past := time.Now().Add(-time.Hour)
+
+	_, err = before.SchedulePersistent(past, "delete-upload", map[string]string{ "file": "missed.txt" }, scheduler.JobOptions{
+		ID: "missed",
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = before.SchedulePersistent(past, "delete-upload", nil, scheduler.JobOptions{
+		ID: "skipped",
+		Misfire: scheduler.MisfireSkip,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = before.SchedulePersistent(time.Now().Add(24 * time.Hour), "delete-upload", nil, scheduler.JobOptions{
+		ID: "future",
+		Overlap: scheduler.OverlapSkip,
+		Retry: scheduler.RetryPolicy{ Attempts: 2, Initial: time.Second },
+		Tags: []string{ "uploads" },
+		Group: "cleanup",
+		Singleton: true,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := store.Load()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(stored) != 3 {
+		t.Fatal("expected 3 stored jobs, got:", len(stored))
+	}
+
+	// restart
+	after := scheduler.NewWith(options)
+
+	payloads := make(chan string, 10)
+
+	after.Handle("delete-upload", func(ctx context.Context, payload json.RawMessage) error {
+		var data map[string]string
+
+		err := json.Unmarshal(payload, &data)
+
+		payloads <- data["file"]
+
+		return err
+	})
+
+	err = after.Restore()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if after.Len() != 2 {
+		t.Error("expected missed and future job to be restored, got:", after.Len())
+	}
+
+	// job options survive the restart
+	info, _ := after.Get("future")
+
+	if info.Overlap != scheduler.OverlapSkip || !info.Singleton || info.Group != "cleanup" || len(info.Tags) != 1 || info.Tags[0] != "uploads" {
+		t.Error("expected restored job options, got:", info)
+	}
+
+	results := make(chan string, 10)
+
+	after.OnResult(func(id string, err error) {
+		if err != nil {
+			t.Error(id, err)
+		}
+
+		results <- id
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go after.Run(ctx)
+
+	select {
+	case id := <-results:
+		if id != "missed" {
+			t.Error("expected missed job to run, got:", id)
+		}
+
+		if file := <-payloads; file != "missed.txt" {
+			t.Error("expected payload missed.txt, got:", file)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for missed job")
+	}
+
+	stored, err = store.Load()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(stored) != 1 || stored[0].ID != "future" {
+		t.Error("expected only future job to be stored, got:", stored)
+	} else if stored[0].Retry.Attempts != 2 || stored[0].Retry.Initial != time.Second {
+		t.Error("expected retry policy to be stored, got:", stored[0].Retry)
+	}
+
+	after.Cancel("future")
+
+	stored, _ = store.Load()
+
+	if len(stored) != 0 {
+		t.Error("expected cancelled job to be removed from store, got:", stored)
+	}
+}
+
+func TestSchedulerRetriesAndHistory(t *testing.T) {
+	clock := scheduler.NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	options := scheduler.DefaultOptions()
+	options.Clock = clock
+
+	s := scheduler.NewWith(options)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s.Start(ctx)
+
+	results := make(chan error, 10)
+
+	s.OnResult(func(id string, err error) {
+		results <- err
+	})
+
+	var attempts atomic.Int32
+
+	s.Schedule(clock.Now(), func(ctx context.Context) error {
+		switch attempts.Add(1) {
+		case 1:
+			return errors.New("temporary")
+		case 2:
+			panic("boom")
+		default:
+			return nil
+		}
+	}, scheduler.JobOptions{
+		ID: "flaky",
+		Retry: scheduler.RetryPolicy{
+			Attempts: 3,
+			Initial: time.Second,
+		},
+	})
+
+	clock.Advance(0)
+	s.Wait()
+
+	clock.Advance(time.Second)
+	s.Wait()
+
+	if attempts.Load() != 2 {
+		t.Fatal("expected 2 attempts, got:", attempts.Load())
+	}
+
+	// second retry waits 2s
+	clock.Advance(time.Second)
+	s.Wait()
+
+	if attempts.Load() != 2 {
+		t.Fatal("expected retry to back off, got:", attempts.Load())
+	}
+
+	clock.Advance(time.Second)
+	s.Wait()
+
+	if err := <-results; err != nil {
+		t.Error("expected final attempt to succeed, got:", err)
+	}
+
+	history, ok := s.History("flaky")
+
+	if !ok || len(history) != 3 {
+		t.Fatal("expected 3 history records, got:", history)
+	}
+
+	expected := []scheduler.Outcome{ scheduler.OutcomeFailed, scheduler.OutcomePanicked, scheduler.OutcomeSucceeded }
+
+	for i, record := range history {
+		if record.Outcome != expected[i] || record.Attempt != i {
+			t.Error("\nExpected: ", expected[i], i, "\nGot: ", record.Outcome, record.Attempt)
+		}
+	}
+
+	var panicErr *scheduler.PanicError
+
+	if !errors.As(history[1].Err, &panicErr) || panicErr.Value != "boom" {
+		t.Error("expected panic error, got:", history[1].Err)
+	}
+
+	s.Schedule(clock.Now(), func(ctx context.Context) error {
+		panic("unrecovered")
+	}, scheduler.JobOptions{
+		ID: "panics",
+	})
+
+	clock.Advance(0)
+	s.Wait()
+
+	if err := <-results; !errors.As(err, &panicErr) {
+		t.Error("expected panic to be reported, got:", err)
+	}
+
+	history, _ = s.History("panics")
+
+	if len(history) != 1 || history[0].Outcome != scheduler.OutcomePanicked {
+		t.Error("expected panicked run in history, got:", history)
+	}
+
+	var runs atomic.Int32
+
+	s.Schedule(clock.Now(), func(ctx context.Context) error {
+		runs.Add(1)
+		return nil
+	}, scheduler.JobOptions{
+		ID: "bounded",
+		Repeat: scheduler.Interval(time.Second),
+		History: 2,
+	})
+
+	for range 5 {
+		clock.Advance(time.Second)
+		s.Wait()
+	}
+
+	history, _ = s.History("bounded")
+
+	if len(history) != 2 || !history[1].Start.Equal(clock.Now()) {
+		t.Error("expected last 2 runs in history, got:", history)
+	}
+
+	s.Cancel("bounded")
+
+	history, ok = s.History("bounded")
+
+	if !ok || len(history) != 2 {
+		t.Error("expected history of cancelled job to be retained, got:", history)
+	}
+}
+
+func TestSchedulerManagement(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := scheduler.NewFakeClock(start)
+
+	options := scheduler.DefaultOptions()
+	options.Clock = clock
+
+	s := scheduler.NewWith(options)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s.Start(ctx)
+
+	counts := map[string]*atomic.Int32{
+		"once": {},
+		"repeat": {},
+		"backoff": {},
+	}
+
+	count := func(id string) scheduler.JobFunc {
+		return func(ctx context.Context) error {
+			counts[id].Add(1)
+			return nil
+		}
+	}
+
+	s.Schedule(start.Add(time.Hour), count("once"), scheduler.JobOptions{ ID: "once" })
+	s.Schedule(start.Add(time.Minute), count("repeat"), scheduler.JobOptions{
+		ID: "repeat",
+		Repeat: scheduler.Interval(time.Minute),
+	})
+	s.Schedule(start.Add(time.Second), count("backoff"), scheduler.JobOptions{
+		ID: "backoff",
+		Repeat: scheduler.Backoff(time.Second, time.Minute, 2),
+	})
+
+	list := s.List()
+
+	if len(list) != 3 || list[0].ID != "backoff" || list[1].ID != "repeat" || list[2].ID != "once" {
+		t.Fatal("expected jobs sorted by next run, got:", list)
+	}
+
+	info, ok := s.Get("repeat")
+
+	if !ok || !info.Repeating || !info.NextRun.Equal(start.Add(time.Minute)) {
+		t.Error("unexpected snapshot:", info)
+	}
+
+	expectedRuns := []time.Time{ start.Add(time.Second), start.Add(2 * time.Second), start.Add(4 * time.Second), start.Add(8 * time.Second) }
+
+	// previewing doesn't advance stateful policies
+	for range 2 {
+		runs := s.NextRuns("backoff", 4)
+
+		if !slices.Equal(runs, expectedRuns) {
+			t.Error("\nExpected: ", expectedRuns, "\nGot: ", runs)
+		}
+	}
+
+	s.Cancel("backoff")
+
+	if !s.Reschedule("once", start.Add(30 * time.Second)) {
+		t.Error("expected once to be rescheduled")
+	}
+
+	clock.Advance(30 * time.Second)
+	s.Wait()
+
+	if counts["once"].Load() != 1 {
+		t.Error("expected rescheduled job to run, got:", counts["once"].Load())
+	}
+
+	if !s.TriggerNow("repeat") {
+		t.Error("expected repeat to be triggered")
+	}
+
+	s.Wait()
+
+	info, _ = s.Get("repeat")
+
+	if counts["repeat"].Load() != 1 || !info.NextRun.Equal(start.Add(time.Minute)) {
+		t.Error("expected triggered run without changing schedule, got:", counts["repeat"].Load(), info.NextRun)
+	}
+
+	s.Pause("repeat")
+
+	clock.Advance(10 * time.Minute)
+	s.Wait()
+
+	if counts["repeat"].Load() != 1 {
+		t.Error("expected paused job to not run, got:", counts["repeat"].Load())
+	}
+
+	if info, _ := s.Get("repeat"); !info.Paused {
+		t.Error("expected job to be paused")
+	}
+
+	// missed runs are skipped
+	s.Resume("repeat")
+
+	info, _ = s.Get("repeat")
+
+	if !info.NextRun.Equal(start.Add(11 * time.Minute)) {
+		t.Error("expected job to be realigned, got:", info.NextRun)
+	}
+
+	clock.Advance(time.Minute)
+	s.Wait()
+
+	if counts["repeat"].Load() != 2 {
+		t.Error("expected resumed job to run once, got:", counts["repeat"].Load())
+	}
+
+	s.PauseAll()
+
+	clock.Advance(5 * time.Minute)
+	s.Wait()
+
+	if counts["repeat"].Load() != 2 {
+		t.Error("expected paused scheduler to not fire, got:", counts["repeat"].Load())
+	}
+
+	s.ResumeAll()
+
+	clock.Advance(time.Minute)
+	s.Wait()
+
+	if counts["repeat"].Load() != 3 {
+		t.Error("expected resumed scheduler to fire once, got:", counts["repeat"].Load())
+	}
+
+	// adding a job with an existing ID replaces it
+	s.Schedule(clock.Now().Add(time.Hour), count("once"), scheduler.JobOptions{ ID: "repeat" })
+
+	info, _ = s.Get("repeat")
+
+	if s.Len() != 1 || info.Repeating || len(info.History) != 3 {
+		t.Error("expected job to be replaced and keep its history, got:", s.Len(), info)
+	}
+
+	if _, ok := s.Get("unknown"); ok || s.Reschedule("unknown", start) || s.TriggerNow("unknown") || s.Pause("unknown") {
+		t.Error("expected unknown job to not be found")
+	}
+}
+
+func TestSchedulerTags(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := scheduler.NewFakeClock(start)
+
+	s := scheduler.NewWith(scheduler.Options{
+		Clock: clock,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s.Start(ctx)
+
+	var runs atomic.Int32
+
+	for user := range 100 {
+		for i := range 10 {
+			s.Schedule(start.Add(time.Duration(i + 1) * time.Minute), func(ctx context.Context) error {
+				runs.Add(1)
+				return nil
+			}, scheduler.JobOptions{
+				Tags: []string{ "user:" + strconv.Itoa(user), "cleanup" },
+				Group: "group:" + strconv.Itoa(user % 10),
+			})
+		}
+	}
+
+	if jobs := s.ListByTag("user:42"); len(jobs) != 10 || jobs[0].Group != "group:2" {
+		t.Error("expected 10 jobs for user:42, got:", len(jobs))
+	}
+
+	if cancelled := s.CancelByTag("user:42"); cancelled != 10 {
+		t.Error("expected 10 cancelled jobs, got:", cancelled)
+	}
+
+	if s.Len() != 990 || len(s.ListByTag("user:42")) != 0 || s.CancelByTag("user:42") != 0 {
+		t.Error("expected user:42 jobs to be gone, got:", s.Len())
+	}
+
+	if paused := s.PauseGroup("group:0"); paused != 100 {
+		t.Error("expected 100 paused jobs, got:", paused)
+	}
+
+	clock.Advance(10 * time.Minute)
+	s.Wait()
+
+	if runs.Load() != 890 {
+		t.Error("expected 890 runs, got:", runs.Load())
+	}
+
+	// finished jobs leave the index
+	if jobs := s.ListByTag("cleanup"); len(jobs) != 100 {
+		t.Error("expected only paused jobs to be left, got:", len(jobs))
+	}
+
+	if resumed := s.ResumeGroup("group:0"); resumed != 100 {
+		t.Error("expected 100 resumed jobs, got:", resumed)
+	}
+
+	clock.Advance(0)
+	s.Wait()
+
+	if runs.Load() != 990 || len(s.ListGroup("group:0")) != 0 {
+		t.Error("expected missed jobs to run after resuming, got:", runs.Load())
+	}
+}
+
+func TestSchedulerDebounce(t *testing.T) {
+	clock := scheduler.NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	s := scheduler.NewWith(scheduler.Options{
+		Clock: clock,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s.Start(ctx)
+
+	var runs atomic.Int32
+	var last atomic.Int32
+
+	call := func(n int32) func() {
+		return func() {
+			runs.Add(1)
+			last.Store(n)
+		}
+	}
+
+	for i := range 5 {
+		s.Debounce("reload", 100 * time.Millisecond, call(int32(i)))
+
+		clock.Advance(50 * time.Millisecond)
+		s.Wait()
+	}
+
+	if runs.Load() != 0 {
+		t.Error("expected debounced fn to not run during burst, got:", runs.Load())
+	}
+
+	clock.Advance(50 * time.Millisecond)
+	s.Wait()
+
+	if runs.Load() != 1 || last.Load() != 4 {
+		t.Error("expected latest fn to run once, got:", runs.Load(), last.Load())
+	}
+
+	// max wait
+	for i := range 10 {
+		s.DebounceWithMaxWait("flush", 100 * time.Millisecond, 220 * time.Millisecond, call(int32(i)))
+
+		clock.Advance(50 * time.Millisecond)
+		s.Wait()
+	}
+
+	// runs at 220ms and 450ms (burst restarts at 250ms)
+	if runs.Load() != 3 {
+		t.Error("expected max wait to force runs, got:", runs.Load())
+	}
+
+	var wg sync.WaitGroup
+
+	for i := range 50 {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			s.Debounce("concurrent", time.Second, call(int32(i)))
+		}()
+	}
+
+	wg.Wait()
+
+	clock.Advance(time.Second)
+	s.Wait()
+
+	if runs.Load() != 4 || s.Len() != 0 {
+		t.Error("expected concurrent calls to run once, got:", runs.Load(), s.Len())
+	}
+
+	// debouncing again doesn't cancel the started run (waiting for a worker)
+	limited := scheduler.NewWith(scheduler.Options{
+		Clock: clock,
+		MaxConcurrency: 1,
+	})
+
+	limited.Start(ctx)
+
+	block := make(chan struct{})
+
+	limited.Schedule(clock.Now(), func(ctx context.Context) error {
+		<-block
+		return nil
+	}, scheduler.JobOptions{})
+
+	clock.Advance(0)
+
+	limited.Debounce("save", 10 * time.Millisecond, call(5))
+
+	clock.Advance(10 * time.Millisecond)
+
+	limited.Debounce("save", 10 * time.Millisecond, call(6))
+
+	close(block)
+	limited.Wait()
+
+	if runs.Load() != 5 || last.Load() != 5 {
+		t.Error("expected started run to finish, got:", runs.Load(), last.Load())
+	}
+
+	clock.Advance(10 * time.Millisecond)
+	limited.Wait()
+
+	if runs.Load() != 6 || last.Load() != 6 {
+		t.Error("expected next debounced run, got:", runs.Load(), last.Load())
+	}
+}
+
+func TestSchedulerThrottle(t *testing.T) {
+	clock := scheduler.NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	s := scheduler.NewWith(scheduler.Options{
+		Clock: clock,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s.Start(ctx)
+
+	tests := []struct {
+		name		string
+		options		scheduler.ThrottleOptions
+		expected	[]int32
+	}{
+		{ "leading and trailing", scheduler.ThrottleOptions{ Leading: true, Trailing: true }, []int32{ 0, 4, 9 } },
+		{ "leading", scheduler.ThrottleOptions{ Leading: true }, []int32{ 0, 5 } },
+		{ "trailing", scheduler.ThrottleOptions{ Trailing: true }, []int32{ 4, 9 } },
+	}
+
+	for _, test := range tests {
+		var mutex sync.Mutex
+		runs := []int32{}
+
+		// a call every 20ms for 200ms, interval 100ms
+		for i := range 10 {
+			s.ThrottleWith(test.name, 100 * time.Millisecond, func() {
+				mutex.Lock()
+				runs = append(runs, int32(i))
+				mutex.Unlock()
+			}, test.options)
+
+			clock.Advance(0)
+			s.Wait()
+
+			clock.Advance(20 * time.Millisecond)
+			s.Wait()
+		}
+
+		clock.Advance(time.Second)
+		s.Wait()
+
+		mutex.Lock()
+
+		if !slices.Equal(runs, test.expected) {
+			t.Error(test.name, "\nExpected: ", test.expected, "\nGot: ", runs)
+		}
+
+		mutex.Unlock()
+	}
+
+	// cancelling resets the interval
+	var runs atomic.Int32
+
+	s.Throttle("reset", time.Minute, func() {
+		runs.Add(1)
+	})
+
+	clock.Advance(0)
+	s.Wait()
+
+	s.Throttle("reset", time.Minute, func() {
+		runs.Add(1)
+	})
+
+	s.Cancel("reset")
+
+	s.Throttle("reset", time.Minute, func() {
+		runs.Add(1)
+	})
+
+	clock.Advance(0)
+	s.Wait()
+
+	if runs.Load() != 2 {
+		t.Error("expected leading run after cancel, got:", runs.Load())
+	}
+
+	// throttle calls aren't merged into a debounced job with the same id
+	var throttled atomic.Int32
+
+	s.Debounce("shared", time.Minute, func() {})
+
+	s.Throttle("shared", time.Minute, func() {
+		throttled.Add(1)
+	})
+
+	clock.Advance(0)
+	s.Wait()
+
+	if throttled.Load() != 1 {
+		t.Error("expected leading throttle run, got:", throttled.Load())
+	}
+}
+
+func TestSchedulerWorkflows(t *testing.T) {
+	clock := scheduler.NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	options := scheduler.DefaultOptions()
+	options.Clock = clock
+
+	s := scheduler.NewWith(options)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s.Start(ctx)
+
+	var mutex sync.Mutex
+	order := []string{}
+
+	step := func(name string, err error, dependsOn ...string) scheduler.Step {
+		return scheduler.Step{
+			Name: name,
+			DependsOn: dependsOn,
+			Fn: func(ctx context.Context) error {
+				mutex.Lock()
+				order = append(order, name)
+				mutex.Unlock()
+
+				return err
+			},
+		}
+	}
+
+	// export => compress (fan-out) => upload (fan-in)
+	nightly, err := scheduler.NewWorkflow("nightly",
+		step("export", nil),
+		step("compress-db", nil, "export"),
+		step("compress-files", nil, "export"),
+		step("upload", nil, "compress-db", "compress-files"),
+	)
+
+	if err != nil {
+		t.Fatal("expected valid workflow, got:", err)
+	}
+
+	id := s.ScheduleWorkflow(clock.Now().Add(time.Hour), nightly, scheduler.JobOptions{
+		Repeat: scheduler.Interval(24 * time.Hour),
+	})
+
+	clock.Advance(time.Hour)
+	s.Wait()
+
+	runs := s.WorkflowRuns("nightly")
+
+	if len(runs) != 1 || !runs[0].Succeeded() || runs[0].End.IsZero() {
+		t.Fatal("expected one succeeded run, got:", runs)
+	}
+
+	if len(order) != 4 || order[0] != "export" || order[3] != "upload" {
+		t.Error("expected steps to run in dependency order, got:", order)
+	}
+
+	if _, ok := s.Get(id); !ok {
+		t.Error("expected workflow job to stay scheduled")
+	}
+
+	// workflow jobs last until their run is finished
+	if history, _ := s.History(id); len(history) != 1 || history[0].Outcome != scheduler.OutcomeSucceeded {
+		t.Error("expected succeeded workflow job, got:", history)
+	}
+
+	// failures skip dependents
+	order = nil
+
+	failing, _ := scheduler.NewWorkflow("failing",
+		step("export", errors.New("disk full")),
+		step("compress", nil, "export"),
+		step("upload", nil, "compress"),
+		step("notify", nil),
+	)
+
+	run := s.RunWorkflow(failing)
+	s.Wait()
+
+	info, ok := s.WorkflowRun(run)
+
+	if !ok || info.Succeeded() || info.End.IsZero() {
+		t.Fatal("expected finished failed run, got:", info)
+	}
+
+	expected := map[string]scheduler.StepState{
+		"export": scheduler.StepFailed,
+		"compress": scheduler.StepSkipped,
+		"upload": scheduler.StepSkipped,
+		"notify": scheduler.StepSucceeded,
+	}
+
+	for name, state := range expected {
+		if info.Steps[name].State != state {
+			t.Error("expected step", name, "to be", state, "got:", info.Steps[name].State)
+		}
+	}
+
+	if info.Steps["export"].Err == nil {
+		t.Error("expected failed step to keep its error")
+	}
+
+	if slices.Contains(order, "compress") || slices.Contains(order, "upload") {
+		t.Error("expected skipped steps to not run, got:", order)
+	}
+
+	failingJob := s.ScheduleWorkflow(clock.Now(), failing, scheduler.JobOptions{})
+
+	clock.Advance(0)
+	s.Wait()
+
+	var workflowErr *scheduler.WorkflowError
+
+	if history, _ := s.History(failingJob); len(history) != 1 || history[0].Outcome != scheduler.OutcomeFailed ||
+		!errors.As(history[0].Err, &workflowErr) || workflowErr.Run.Steps["export"].State != scheduler.StepFailed {
+		t.Error("expected failed workflow job, got:", history)
+	}
+
+	// timed out workflow jobs cancel their run
+	slowStarted := make(chan struct{})
+
+	slow, _ := scheduler.NewWorkflow("slow",
+		scheduler.Step{
+			Name: "wait",
+			Fn: func(ctx context.Context) error {
+				close(slowStarted)
+				<-ctx.Done()
+				return ctx.Err()
+			},
+		},
+	)
+
+	slowJob := s.ScheduleWorkflow(clock.Now(), slow, scheduler.JobOptions{
+		Timeout: time.Minute,
+	})
+
+	clock.Advance(0)
+
+	<-slowStarted
+
+	clock.Advance(time.Minute)
+	s.Wait()
+
+	if history, _ := s.History(slowJob); len(history) != 1 || history[0].Outcome != scheduler.OutcomeTimedOut {
+		t.Error("expected timed out workflow job, got:", history)
+	}
+
+	if runs := s.WorkflowRuns("slow"); len(runs) != 1 || runs[0].Steps["wait"].State != scheduler.StepCancelled {
+		t.Error("expected cancelled workflow run, got:", runs)
+	}
+
+	// waiting for steps doesn't take up a worker
+	limitedOptions := scheduler.DefaultOptions()
+	limitedOptions.Clock = clock
+	limitedOptions.MaxConcurrency = 1
+
+	limited := scheduler.NewWith(limitedOptions)
+
+	limited.Start(ctx)
+
+	limitedJob := limited.ScheduleWorkflow(clock.Now(), nightly, scheduler.JobOptions{})
+
+	clock.Advance(0)
+	limited.Wait()
+
+	if history, _ := limited.History(limitedJob); len(history) != 1 || history[0].Outcome != scheduler.OutcomeSucceeded {
+		t.Error("expected workflow job to finish with MaxConcurrency 1, got:", history)
+	}
+
+	// snapshot of running workflow
+	release := make(chan struct{})
+
+	blocking, _ := scheduler.NewWorkflow("blocking",
+		scheduler.Step{
+			Name: "wait",
+			Fn: func(ctx context.Context) error {
+				select {
+				case <-release:
+					return nil
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			},
+		},
+		step("after", nil, "wait"),
+	)
+
+	run = s.RunWorkflow(blocking)
+
+	info, _ = s.WorkflowRun(run)
+
+	if !info.End.IsZero() || info.Steps["after"].State != scheduler.StepPending {
+		t.Error("expected running workflow, got:", info)
+	}
+
+	if !s.CancelWorkflowRun(run) {
+		t.Error("expected run to be cancelled")
+	}
+
+	s.Wait()
+	close(release)
+
+	info, _ = s.WorkflowRun(run)
+
+	if info.End.IsZero() || info.Steps["wait"].State != scheduler.StepCancelled || info.Steps["after"].State != scheduler.StepCancelled {
+		t.Error("expected cancelled run, got:", info)
+	}
+
+	// queued steps that are cancelled or removed (scheduler isn't started), finished runs are retained by default
+	stopped := scheduler.NewWith(scheduler.Options{
+		Clock: clock,
+	})
+
+	run = stopped.RunWorkflow(nightly)
+
+	stopped.Cancel(run + "/export")
+
+	info, ok = stopped.WorkflowRun(run)
+
+	if !ok || info.End.IsZero() || info.Steps["export"].State != scheduler.StepCancelled || info.Steps["upload"].State != scheduler.StepSkipped {
+		t.Error("expected cancelled step to finish the run, got:", info, ok)
+	}
+
+	run = stopped.RunWorkflow(nightly)
+
+	stopped.Pop()
+
+	info, ok = stopped.WorkflowRun(run)
+
+	if !ok || info.End.IsZero() || info.Steps["export"].State != scheduler.StepCancelled {
+		t.Error("expected removed step to finish the run, got:", info, ok)
+	}
+
+	// steps don't start while shutting down
+	release = make(chan struct{})
+	started := make(chan struct{})
+
+	draining, _ := scheduler.NewWorkflow("draining",
+		scheduler.Step{
+			Name: "wait",
+			Fn: func(ctx context.Context) error {
+				close(started)
+				<-release
+				return nil
+			},
+		},
+		step("after", nil, "wait"),
+	)
+
+	run = s.RunWorkflow(draining)
+
+	<-started
+
+	expired, expiredCancel := context.WithCancel(context.Background())
+	expiredCancel()
+
+	s.Shutdown(expired)
+
+	close(release)
+	s.Wait()
+
+	info, _ = s.WorkflowRun(run)
+
+	if info.End.IsZero() || info.Steps["wait"].State != scheduler.StepSucceeded || info.Steps["after"].State != scheduler.StepCancelled {
+		t.Error("expected dependent step to be cancelled on shutdown, got:", info)
+	}
+
+	// validation
+	_, err = scheduler.NewWorkflow("cycle",
+		step("a", nil, "c"),
+		step("b", nil, "a"),
+		step("c", nil, "b"),
+	)
+
+	var cycle *scheduler.WorkflowCycleError
+
+	if !errors.As(err, &cycle) {
+		t.Error("expected cycle error, got:", err)
+	}
+
+	_, err = scheduler.NewWorkflow("unknown", step("a", nil, "missing"))
+
+	if err == nil {
+		t.Error("expected error for unknown dependency")
+	}
+}
+
+func TestSchedulerMisfires(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := scheduler.NewFakeClock(start)
+
+	options := scheduler.DefaultOptions()
+	options.Clock = clock
+
+	s := scheduler.NewWith(options)
+
+	type jump struct {
+		expected	time.Time
+		actual		time.Time
+	}
+
+	var mutex sync.Mutex
+	jumps := []jump{}
+
+	s.OnClockJump(func(expected time.Time, actual time.Time) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		jumps = append(jumps, jump{ expected, actual })
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s.Start(ctx)
+
+	counters := map[scheduler.MisfirePolicy]*atomic.Int32{}
+
+	for _, policy := range []scheduler.MisfirePolicy{ scheduler.MisfireRunOnce, scheduler.MisfireSkip, scheduler.MisfireRunAll } {
+		counter := &atomic.Int32{}
+		counters[policy] = counter
+
+		s.Schedule(start.Add(time.Minute), func(ctx context.Context) error {
+			counter.Add(1)
+			return nil
+		}, scheduler.JobOptions{
+			ID: "policy-" + strconv.Itoa(int(policy)),
+			Repeat: scheduler.Interval(time.Minute),
+			Misfire: policy,
+		})
+	}
+
+	oneOff := s.Schedule(start.Add(time.Minute), func(ctx context.Context) error {
+		t.Error("expected missed one-off job to be dropped")
+		return nil
+	}, scheduler.JobOptions{
+		Misfire: scheduler.MisfireSkip,
+	})
+
+	// host is suspended for 5 minutes, the timer fires late
+	clock.Advance(30 * time.Second)
+	clock.Jump(5 * time.Minute)
+	clock.Advance(30 * time.Second)
+	s.Wait()
+
+	if counters[scheduler.MisfireRunOnce].Load() != 1 {
+		t.Error("expected run once policy to run once, got:", counters[scheduler.MisfireRunOnce].Load())
+	}
+
+	if counters[scheduler.MisfireSkip].Load() != 0 {
+		t.Error("expected skip policy to not run, got:", counters[scheduler.MisfireSkip].Load())
+	}
+
+	if counters[scheduler.MisfireRunAll].Load() != 6 {
+		t.Error("expected run all policy to run every missed run, got:", counters[scheduler.MisfireRunAll].Load())
+	}
+
+	for _, policy := range []scheduler.MisfirePolicy{ scheduler.MisfireRunOnce, scheduler.MisfireSkip, scheduler.MisfireRunAll } {
+		info, _ := s.Get("policy-" + strconv.Itoa(int(policy)))
+
+		if !info.NextRun.Equal(start.Add(7 * time.Minute)) {
+			t.Error("expected policy", policy, "to realign to next future slot, got:", info.NextRun)
+		}
+	}
+
+	history, _ := s.History("policy-" + strconv.Itoa(int(scheduler.MisfireSkip)))
+
+	if len(history) != 1 || history[0].Outcome != scheduler.OutcomeMissed {
+		t.Error("expected missed run in history, got:", history)
+	}
+
+	if _, ok := s.Get(oneOff); ok {
+		t.Error("expected missed one-off job to be removed")
+	}
+
+	mutex.Lock()
+
+	if len(jumps) != 1 || !jumps[0].expected.Equal(start.Add(time.Minute)) || !jumps[0].actual.Equal(start.Add(6 * time.Minute)) {
+		t.Error("expected forward clock jump, got:", jumps)
+	}
+
+	mutex.Unlock()
+
+	// wall clock is set back, nothing runs early
+	clock.Jump(-10 * time.Minute)
+	clock.Advance(time.Minute)
+	s.Wait()
+
+	mutex.Lock()
+
+	if len(jumps) != 2 || !jumps[1].actual.Before(jumps[1].expected) {
+		t.Error("expected backward clock jump, got:", jumps)
+	}
+
+	mutex.Unlock()
+
+	if counters[scheduler.MisfireRunOnce].Load() != 1 || counters[scheduler.MisfireRunAll].Load() != 6 {
+		t.Error("expected no runs after backward jump")
+	}
+
+	// back in time: regular runs continue
+	clock.Advance(10 * time.Minute)
+	s.Wait()
+
+	if counters[scheduler.MisfireRunOnce].Load() != 2 || counters[scheduler.MisfireSkip].Load() != 1 || counters[scheduler.MisfireRunAll].Load() != 7 {
+		t.Error("expected regular runs to continue, got:",
+			counters[scheduler.MisfireRunOnce].Load(), counters[scheduler.MisfireSkip].Load(), counters[scheduler.MisfireRunAll].Load())
+	}
+}
+
+func TestSchedulerTimesMisfire(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := scheduler.NewFakeClock(start)
+
+	options := scheduler.DefaultOptions()
+	options.Clock = clock
+
+	s := scheduler.NewWith(options)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s.Start(ctx)
+
+	var runs atomic.Int32
+
+	s.Schedule(start.Add(time.Minute), func(ctx context.Context) error {
+		runs.Add(1)
+		return nil
+	}, scheduler.JobOptions{
+		Repeat: scheduler.Times(3, scheduler.Interval(time.Minute)),
+		Misfire: scheduler.MisfireSkip,
+	})
+
+	// missed runs don't count towards Times()
+	clock.Jump(5 * time.Minute)
+	clock.Advance(30 * time.Second)
+	s.Wait()
+
+	for range 5 {
+		clock.Advance(time.Minute)
+		s.Wait()
+	}
+
+	if runs.Load() != 3 {
+		t.Error("expected Times() job to run 3 times after missed runs, got:", runs.Load())
+	}
+}
+
+func TestSchedulerOverdueJobs(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := scheduler.NewFakeClock(start)
+
+	options := scheduler.DefaultOptions()
+	options.Clock = clock
+	options.Store = scheduler.NewFileStore(filepath.Join(t.TempDir(), "jobs.json"))
+
+	s := scheduler.NewWith(options)
+
+	var jumps atomic.Int32
+
+	s.OnClockJump(func(expected time.Time, actual time.Time) {
+		jumps.Add(1)
+	})
+
+	var runs atomic.Int32
+
+	s.Handle("count", func(ctx context.Context, payload json.RawMessage) error {
+		runs.Add(1)
+		return nil
+	})
+
+	// overdue before starting
+	s.AddAt(start.Add(-time.Hour), func() {
+		runs.Add(1)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s.Start(ctx)
+
+	clock.Advance(0)
+	s.Wait()
+
+	// overdue after starting
+	s.AddAt(clock.Now().Add(-time.Hour), func() {
+		runs.Add(1)
+	})
+
+	clock.Advance(0)
+	s.Wait()
+
+	// restored job late by less than MisfireThreshold isn't missed
+	before := scheduler.NewWith(options)
+
+	before.Handle("count", func(ctx context.Context, payload json.RawMessage) error {
+		return nil
+	})
+
+	_, err := before.SchedulePersistent(clock.Now().Add(-500 * time.Millisecond), "count", nil, scheduler.JobOptions{
+		Misfire: scheduler.MisfireSkip,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.Restore()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clock.Advance(0)
+	s.Wait()
+
+	if runs.Load() != 3 {
+		t.Error("expected every overdue job to run, got:", runs.Load())
+	}
+
+	if jumps.Load() != 0 {
+		t.Error("expected no clock jumps for overdue jobs, got:", jumps.Load())
+	}
+}
+
+// Reads time like RealClock (with monotonic readings) until the wall clock is changed
+type Test_WallClock struct {
+	*scheduler.FakeClock
+	offset		atomic.Int64
+}
+
+func (clock *Test_WallClock) Now() time.Time {
+	offset := time.Duration(clock.offset.Load())
+
+	if offset == 0 {
+		return clock.FakeClock.Now()
+	}
+
+	// changed wall clock, timers keep running on the monotonic clock
+	return clock.FakeClock.Now().Round(0).Add(offset)
+}
+
+func TestSchedulerWallClockJump(t *testing.T) {
+	start := time.Now()
+
+	clock := &Test_WallClock{
+		FakeClock: scheduler.NewFakeClock(start),
+	}
+
+	// MisfireThreshold is defaulted
+	s := scheduler.NewWith(scheduler.Options{
+		Clock: clock,
+		ClockJumpThreshold: time.Minute,
+	})
+
+	var jumps atomic.Int32
+
+	s.OnClockJump(func(expected time.Time, actual time.Time) {
+		jumps.Add(1)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s.Start(ctx)
+
+	var slightlyLate atomic.Int32
+
+	s.Schedule(start.Add(time.Minute), func(ctx context.Context) error {
+		slightlyLate.Add(1)
+		return nil
+	}, scheduler.JobOptions{
+		Misfire: scheduler.MisfireSkip,
+	})
+
+	clock.offset.Store(int64(500 * time.Millisecond))
+	clock.Advance(time.Minute)
+	s.Wait()
+
+	if slightlyLate.Load() != 1 {
+		t.Error("expected run within the default misfire threshold")
+	}
+
+	if jumps.Load() != 0 {
+		t.Error("expected no clock jump, got:", jumps.Load())
+	}
+
+	var missed atomic.Int32
+
+	s.Schedule(start.Add(2 * time.Minute), func(ctx context.Context) error {
+		missed.Add(1)
+		return nil
+	}, scheduler.JobOptions{
+		Misfire: scheduler.MisfireSkip,
+	})
+
+	// wall clock is set forward, the timer still fires after one minute
+	clock.offset.Store(int64(10 * time.Minute))
+	clock.Advance(time.Minute)
+	s.Wait()
+
+	if missed.Load() != 0 {
+		t.Error("expected run to be missed after wall clock jump")
+	}
+
+	if jumps.Load() != 1 {
+		t.Error("expected forward clock jump, got:", jumps.Load())
+	}
+}
+
+func TestSchedulerCalendars(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cron := func(expression string) *scheduler.CronPolicy {
+		policy, err := scheduler.ParseCron(expression, berlin)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return policy
+	}
+
+	christmas := scheduler.Holidays(berlin,
+		time.Date(2026, 12, 25, 0, 0, 0, 0, time.UTC),
+		time.Date(2026, 12, 26, 0, 0, 0, 0, time.UTC),
+	)
+
+	tests := []struct {
+		name		string
+		policy		scheduler.RepeatPolicy
+		after		time.Time
+		expected	[]time.Time
+	}{
+		{
+			name: "business days without holidays",
+			policy: scheduler.Exclude(scheduler.Include(cron("0 9 * * *"), scheduler.BusinessDays(berlin)), christmas),
+			after: time.Date(2026, 12, 24, 10, 0, 0, 0, berlin),
+			expected: []time.Time{
+				time.Date(2026, 12, 28, 9, 0, 0, 0, berlin),
+				time.Date(2026, 12, 29, 9, 0, 0, 0, berlin),
+			},
+		},
+		{
+			name: "last day of month",
+			policy: scheduler.Include(cron("0 18 28-31 * *"), scheduler.LastDayOfMonth(berlin)),
+			after: time.Date(2026, 2, 1, 0, 0, 0, 0, berlin),
+			expected: []time.Time{
+				time.Date(2026, 2, 28, 18, 0, 0, 0, berlin),
+				time.Date(2026, 3, 31, 18, 0, 0, 0, berlin),
+				time.Date(2026, 4, 30, 18, 0, 0, 0, berlin),
+			},
+		},
+		{
+			name: "every 2nd tuesday",
+			policy: scheduler.Include(cron("0 9 * * TUE"), scheduler.NthWeekday(berlin, 2, time.Tuesday)),
+			after: time.Date(2026, 1, 1, 0, 0, 0, 0, berlin),
+			expected: []time.Time{
+				time.Date(2026, 1, 13, 9, 0, 0, 0, berlin),
+				time.Date(2026, 2, 10, 9, 0, 0, 0, berlin),
+			},
+		},
+		{
+			name: "last friday",
+			policy: scheduler.Include(cron("0 9 * * FRI"), scheduler.NthWeekday(berlin, -1, time.Friday)),
+			after: time.Date(2026, 1, 1, 0, 0, 0, 0, berlin),
+			expected: []time.Time{
+				time.Date(2026, 1, 30, 9, 0, 0, 0, berlin),
+				time.Date(2026, 2, 27, 9, 0, 0, 0, berlin),
+			},
+		},
+		{
+			name: "nightly blackout",
+			policy: scheduler.Exclude(scheduler.Interval(time.Hour), scheduler.DailyWindow(berlin, 22 * time.Hour, 6 * time.Hour)),
+			after: time.Date(2026, 1, 1, 20, 0, 0, 0, berlin),
+			expected: []time.Time{
+				time.Date(2026, 1, 1, 21, 0, 0, 0, berlin),
+				time.Date(2026, 1, 2, 6, 0, 0, 0, berlin),
+			},
+		},
+		{
+			name: "maintenance window",
+			policy: scheduler.Exclude(cron("0 9 * * *"), scheduler.Window(
+				time.Date(2026, 1, 2, 0, 0, 0, 0, berlin),
+				time.Date(2026, 1, 5, 0, 0, 0, 0, berlin),
+			)),
+			after: time.Date(2026, 1, 1, 0, 0, 0, 0, berlin),
+			expected: []time.Time{
+				time.Date(2026, 1, 1, 9, 0, 0, 0, berlin),
+				time.Date(2026, 1, 5, 9, 0, 0, 0, berlin),
+			},
+		},
+		{
+			name: "union",
+			policy: scheduler.Union(cron("0 9 * * *"), cron("0 17 * * *")),
+			after: time.Date(2026, 1, 1, 10, 0, 0, 0, berlin),
+			expected: []time.Time{
+				time.Date(2026, 1, 1, 17, 0, 0, 0, berlin),
+				time.Date(2026, 1, 2, 9, 0, 0, 0, berlin),
+			},
+		},
+		{
+			name: "union advances the winner only",
+			policy: scheduler.Union(scheduler.Times(3, scheduler.Interval(time.Hour)), cron("30 0 * * *")),
+			after: time.Date(2026, 1, 1, 0, 0, 0, 0, berlin),
+			expected: []time.Time{
+				time.Date(2026, 1, 1, 0, 30, 0, 0, berlin),
+				time.Date(2026, 1, 1, 1, 30, 0, 0, berlin),
+				time.Date(2026, 1, 1, 2, 30, 0, 0, berlin),
+				time.Date(2026, 1, 2, 0, 30, 0, 0, berlin),
+			},
+		},
+		{
+			name: "intersection",
+			policy: scheduler.Intersect(cron("0 9 * * *"), cron("0 * * * MON")),
+			after: time.Date(2026, 1, 1, 0, 0, 0, 0, berlin),
+			expected: []time.Time{
+				time.Date(2026, 1, 5, 9, 0, 0, 0, berlin),
+				time.Date(2026, 1, 12, 9, 0, 0, 0, berlin),
+			},
+		},
+		{
+			name: "never matches",
+			policy: scheduler.Include(cron("0 9 * * *"), scheduler.Weekdays(berlin)),
+			after: time.Date(2026, 1, 1, 0, 0, 0, 0, berlin),
+			expected: []time.Time{ {} },
+		},
+	}
+
+	for _, test := range tests {
+		after := test.after
+
+		for _, expected := range test.expected {
+			next := test.policy.Next(after)
+
+			if !next.Equal(expected) {
+				t.Error(test.name, "\nExpected: ", expected, "\nGot: ", next)
+				break
+			}
+
+			after = next
+		}
+	}
+
+	// calendars are evaluated in their own time zone (saturday morning in tokyo)
+	friday := time.Date(2026, 1, 2, 20, 0, 0, 0, time.UTC)
+
+	if !scheduler.BusinessDays(time.UTC).Contains(friday) || scheduler.BusinessDays(tokyo).Contains(friday) {
+		t.Error("expected business days to respect time zone")
+	}
+
+	if !scheduler.AllOf(scheduler.BusinessDays(time.UTC), scheduler.Not(scheduler.AnyOf(christmas))).Contains(friday) {
+		t.Error("expected combined calendar to contain friday")
+	}
+
+	// stateful policies aren't advanced by previews
+	clock := scheduler.NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	s := scheduler.NewWith(scheduler.Options{
+		Clock: clock,
+	})
+
+	backoff := scheduler.Backoff(time.Hour, 0, 2)
+
+	id := s.AddRepeating(clock.Now(), scheduler.Union(backoff, cron("0 0 1 1 *")), func() {})
+
+	runs := s.NextRuns(id, 3)
+	again := s.NextRuns(id, 3)
+
+	if len(runs) != 3 || !slices.Equal(runs, again) || !runs[2].Equal(clock.Now().Add(3 * time.Hour)) {
+		t.Error("expected repeatable previews, got:", runs, again)
+	}
+}
+
+type flakyLock struct {
+	scheduler.LockBackend
+	failing		atomic.Bool
+}
+
+func (lock *flakyLock) TryLock(key string, owner string, now time.Time, expires time.Time) (bool, error) {
+	if lock.failing.Load() {
+		return false, errors.New("volume unavailable")
+	}
+
+	return lock.LockBackend.TryLock(key, owner, now, expires)
+}
+
+func TestSchedulerSingleton(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := scheduler.NewFakeClock(start)
+
+	path := filepath.Join(t.TempDir(), "scheduler.lock")
+
+	lockA := &flakyLock{ LockBackend: scheduler.NewFileLock(path) }
+
+	instance := func(name string, lock scheduler.LockBackend) *scheduler.Scheduler {
+		options := scheduler.DefaultOptions()
+		options.Clock = clock
+		options.Lock = lock
+		options.Instance = name
+
+		return scheduler.NewWith(options)
+	}
+
+	a := instance("a", lockA)
+	b := instance("b", scheduler.NewFileLock(path))
+
+	var lockErrors atomic.Int32
+
+	a.OnResult(func(id string, err error) {
+		var lockErr *scheduler.LockError
+
+		if errors.As(err, &lockErr) {
+			lockErrors.Add(1)
+		}
+	})
+
+	ctxA, cancelA := context.WithCancel(context.Background())
+	defer cancelA()
+
+	ctxB, cancelB := context.WithCancel(context.Background())
+	defer cancelB()
+
+	a.Start(ctxA)
+	b.Start(ctxB)
+
+	if !a.HoldsLock() || b.HoldsLock() {
+		t.Fatal("expected first instance to hold the lock")
+	}
+
+	var mutex sync.Mutex
+	runs := map[string][]time.Duration{}
+	var everywhere atomic.Int32
+
+	for name, s := range map[string]*scheduler.Scheduler{ "a": a, "b": b } {
+		s.Schedule(start.Add(5 * time.Second), func(ctx context.Context) error {
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			runs[name] = append(runs[name], clock.Now().Sub(start))
+
+			return nil
+		}, scheduler.JobOptions{
+			ID: "cleanup",
+			Repeat: scheduler.Interval(5 * time.Second),
+			Singleton: true,
+		})
+
+		s.Schedule(start.Add(5 * time.Second), func(ctx context.Context) error {
+			everywhere.Add(1)
+			return nil
+		}, scheduler.JobOptions{
+			Repeat: scheduler.Interval(5 * time.Second),
+		})
+	}
+
+	advance := func(duration time.Duration) {
+		for range duration / time.Second {
+			clock.Advance(time.Second)
+
+			a.Wait()
+			b.Wait()
+		}
+	}
+
+	advance(20 * time.Second)
+
+	mutex.Lock()
+
+	if len(runs["a"]) != 4 || len(runs["b"]) != 0 {
+		t.Error("expected singleton job to only run on lock holder, got:", runs)
+	}
+
+	mutex.Unlock()
+
+	if everywhere.Load() != 8 {
+		t.Error("expected regular jobs to run on every instance, got:", everywhere.Load())
+	}
+
+	// renewals fail, the lease (renewed at 20s) expires at 50s and is taken over
+	lockA.failing.Store(true)
+
+	advance(40 * time.Second)
+
+	if a.HoldsLock() || !b.HoldsLock() {
+		t.Error("expected second instance to take over after lease expiry")
+	}
+
+	if lockErrors.Load() == 0 {
+		t.Error("expected failed renewals to be reported")
+	}
+
+	mutex.Lock()
+
+	for _, at := range runs["a"] {
+		if at >= 50 * time.Second {
+			t.Error("expected no runs on first instance after lease expiry, got:", at)
+		}
+	}
+
+	if len(runs["b"]) == 0 {
+		t.Error("expected singleton job to run on new lock holder")
+	}
+
+	for _, at := range runs["b"] {
+		if at < 50 * time.Second {
+			t.Error("expected no runs on second instance before takeover, got:", at)
+		}
+	}
+
+	mutex.Unlock()
+
+	// stopping releases the lock right away
+	lockA.failing.Store(false)
+	cancelB()
+
+	// stopping happens in the background
+	deadline := time.Now().Add(2 * time.Second)
+
+	for time.Now().Before(deadline) {
+		data, _ := os.ReadFile(path)
+
+		if !strings.Contains(string(data), `"b"`) {
+			break
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	advance(10 * time.Second)
+
+	if !a.HoldsLock() {
+		t.Error("expected first instance to acquire released lock")
+	}
+
+	// LockKey and LockTTL are defaulted
+	c := scheduler.NewWith(scheduler.Options{
+		Clock: clock,
+		Lock: scheduler.NewFileLock(filepath.Join(t.TempDir(), "defaults.lock")),
+	})
+
+	ctxC, cancelC := context.WithCancel(context.Background())
+	defer cancelC()
+
+	c.Start(ctxC)
+
+	clock.Advance(time.Minute)
+
+	if !c.HoldsLock() {
+		t.Error("expected lease to be renewed with default TTL")
+	}
+}
+
+func TestSchedulerShutdown(t *testing.T) {
+	clock := scheduler.NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	options := scheduler.DefaultOptions()
+	options.Clock = clock
+
+	s := scheduler.NewWith(options)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s.Start(ctx)
+
+	var started sync.WaitGroup
+	var finished atomic.Int32
+	var repeated atomic.Int32
+
+	started.Add(2)
+
+	release := make(chan struct{})
+	fastDone := make(chan struct{})
+
+	s.ScheduleAfter(0, func(ctx context.Context) error {
+		started.Done()
+		<-release
+
+		finished.Add(1)
+		close(fastDone)
+
+		return nil
+	}, scheduler.JobOptions{ ID: "fast" })
+
+	s.ScheduleAfter(0, func(ctx context.Context) error {
+		started.Done()
+		<-ctx.Done()
+
+		return ctx.Err()
+	}, scheduler.JobOptions{ ID: "stuck" })
+
+	s.ScheduleAfter(50 * time.Millisecond, func(ctx context.Context) error {
+		repeated.Add(1)
+		return nil
+	}, scheduler.JobOptions{
+		Repeat: scheduler.Interval(10 * time.Millisecond),
+	})
+
+	clock.Advance(0)
+
+	started.Wait()
+
+	shutdownCtx, shutdownCancel := context.WithCancel(context.Background())
+	defer shutdownCancel()
+
+	errs := make(chan error, 1)
+
+	go func() {
+		errs <- s.Shutdown(shutdownCtx)
+	}()
+
+	// fast job finishes, then the timeout hits
+	close(release)
+	<-fastDone
+
+	shutdownCancel()
+
+	err := <-errs
+
+	var shutdownErr *scheduler.ShutdownError
+
+	if !errors.As(err, &shutdownErr) || !slices.Equal(shutdownErr.Interrupted, []string{ "stuck" }) {
+		t.Fatal("expected stuck job to be interrupted, got:", err)
+	}
+
+	if finished.Load() != 1 {
+		t.Error("expected in-flight job to finish")
+	}
+
+	s.Wait()
+
+	history, _ := s.History("stuck")
+
+	if len(history) != 1 || history[0].Outcome != scheduler.OutcomeCancelled {
+		t.Error("expected interrupted run to be cancelled, got:", history)
+	}
+
+	clock.Advance(100 * time.Millisecond)
+	s.Wait()
+
+	if repeated.Load() != 0 {
+		t.Error("expected no firings after shutdown, got:", repeated.Load())
+	}
+
+	if s.TriggerNow("fast") {
+		t.Error("expected trigger to be rejected after shutdown")
+	}
+
+	// drained in time
+	s = scheduler.NewWith(options)
+	s.Start(ctx)
+
+	release = make(chan struct{})
+	running := make(chan struct{})
+
+	s.ScheduleAfter(0, func(ctx context.Context) error {
+		close(running)
+		<-release
+
+		return nil
+	}, scheduler.JobOptions{})
+
+	clock.Advance(0)
+
+	<-running
+
+	go func() {
+		errs <- s.Shutdown(context.Background())
+	}()
+
+	close(release)
+
+	if err := <-errs; err != nil {
+		t.Error("expected clean shutdown, got:", err)
+	}
+
+	// retries are dropped and Run() returns
+	s = scheduler.NewWith(options)
+
+	var attempts atomic.Int32
+
+	s.Schedule(clock.Now(), func(ctx context.Context) error {
+		attempts.Add(1)
+		return errors.New("temporary")
+	}, scheduler.JobOptions{
+		ID: "flaky",
+		Retry: scheduler.RetryPolicy{
+			Attempts: 3,
+			Initial: time.Hour,
+		},
+	})
+
+	returned := make(chan struct{})
+
+	go func() {
+		s.Run(ctx)
+		close(returned)
+	}()
+
+	// started by Run()
+	for attempts.Load() == 0 {
+		clock.Advance(0)
+		s.Wait()
+		runtime.Gosched()
+	}
+
+	err = s.Shutdown(context.Background())
+
+	if !errors.As(err, &shutdownErr) || !slices.Equal(shutdownErr.Dropped, []string{ "flaky" }) || len(shutdownErr.Interrupted) != 0 {
+		t.Error("expected dropped retry, got:", err)
+	}
+
+	select {
+	case <-returned:
+	case <-time.After(time.Second):
+		t.Error("expected Run() to return after shutdown")
+	}
+
+	clock.Advance(2 * time.Hour)
+	s.Wait()
+
+	if attempts.Load() != 1 {
+		t.Error("expected no retries after shutdown, got:", attempts.Load())
+	}
+
+	history, _ = s.History("flaky")
+
+	if len(history) != 2 || history[1].Outcome != scheduler.OutcomeCancelled {
+		t.Error("expected dropped retry to be cancelled, got:", history)
+	}
+}
+
+func TestSchedulerMetrics(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := scheduler.NewFakeClock(start)
+
+	options := scheduler.DefaultOptions()
+	options.Clock = clock
+
+	s := scheduler.NewWith(options)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s.Start(ctx)
+
+	s.Schedule(start.Add(time.Second), func(ctx context.Context) error {
+		return nil
+	}, scheduler.JobOptions{})
+
+	s.Schedule(start.Add(time.Second), func(ctx context.Context) error {
+		return errors.New("failed")
+	}, scheduler.JobOptions{})
+
+	var attempts atomic.Int32
+
+	// succeeds on retry
+	s.Schedule(start.Add(time.Second), func(ctx context.Context) error {
+		if attempts.Add(1) == 1 {
+			return errors.New("temporary")
+		}
+
+		return nil
+	}, scheduler.JobOptions{
+		Retry: scheduler.RetryPolicy{
+			Attempts: 1,
+			Initial: time.Second,
+		},
+	})
+
+	s.Schedule(start.Add(2 * time.Second), func(ctx context.Context) error {
+		return nil
+	}, scheduler.JobOptions{
+		Misfire: scheduler.MisfireSkip,
+	})
+
+	clock.Advance(time.Second)
+	s.Wait()
+
+	// fires 10s late
+	clock.Jump(10 * time.Second)
+	clock.Advance(time.Second)
+	s.Wait()
+
+	metrics := s.Metrics()
+
+	if metrics.Fired != 3 || metrics.Attempts != 4 || metrics.Succeeded != 2 || metrics.Failed != 1 || metrics.Skipped != 1 {
+		t.Error("expected counters to match runs, got:", metrics)
+	}
+
+	if metrics.Durations.Count != 4 {
+		t.Error("expected durations of every attempt, got:", metrics.Durations.Count)
+	}
+
+	lateness := metrics.Lateness
+
+	if lateness.Count != 4 || lateness.Counts[0] != 3 || lateness.Counts[4] != 1 || lateness.Sum != 10 * time.Second {
+		t.Error("expected lateness of missed job to be observed, got:", lateness)
+	}
+
+	data, err := json.Marshal(metrics.Lateness)
+
+	if err != nil || !strings.Contains(string(data), `{"le":"10s","count":1}`) {
+		t.Error("expected json buckets, got:", string(data), err)
+	}
+}
+
+func TestSchedulerAdminHandler(t *testing.T) {
+	clock := scheduler.NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	options := scheduler.DefaultOptions()
+	options.Clock = clock
+
+	s := scheduler.NewWith(options)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s.Start(ctx)
+
+	var runs atomic.Int32
+
+	s.Schedule(clock.Now().Add(time.Hour), func(ctx context.Context) error {
+		runs.Add(1)
+		return nil
+	}, scheduler.JobOptions{
+		ID: "report",
+		Repeat: scheduler.Interval(time.Hour),
+		Tags: []string{ "reports" },
+	})
+
+	handler := s.AdminHandler()
+
+	request := func(method string, path string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+
+		handler.ServeHTTP(recorder, httptest.NewRequest(method, path, nil))
+
+		return recorder
+	}
+
+	response := request(http.MethodGet, "/jobs")
+
+	var jobs []map[string]any
+
+	err := json.Unmarshal(response.Body.Bytes(), &jobs)
+
+	if response.Code != http.StatusOK || err != nil || len(jobs) != 1 || jobs[0]["id"] != "report" {
+		t.Fatal("expected job list, got:", response.Code, response.Body.String())
+	}
+
+	response = request(http.MethodPost, "/jobs/report/trigger")
+	s.Wait()
+
+	if response.Code != http.StatusAccepted || runs.Load() != 1 {
+		t.Error("expected job to be triggered, got:", response.Code, runs.Load())
+	}
+
+	response = request(http.MethodGet, "/jobs/report")
+
+	if response.Code != http.StatusOK || !strings.Contains(response.Body.String(), `"lastOutcome":"succeeded"`) {
+		t.Error("expected job with last outcome, got:", response.Code, response.Body.String())
+	}
+
+	response = request(http.MethodGet, "/metrics")
+
+	if response.Code != http.StatusOK || !strings.Contains(response.Body.String(), `"fired":1`) {
+		t.Error("expected metrics, got:", response.Code, response.Body.String())
+	}
+
+	if request(http.MethodPost, "/jobs/report/cancel").Code != http.StatusNoContent {
+		t.Error("expected job to be cancelled")
+	}
+
+	if request(http.MethodGet, "/jobs/report").Code != http.StatusNotFound {
+		t.Error("expected cancelled job to be gone")
+	}
+
+	if request(http.MethodPost, "/jobs/missing/trigger").Code != http.StatusNotFound {
+		t.Error("expected unknown job to not be found")
+	}
+
+	if request(http.MethodGet, "/jobs/report/cancel").Code != http.StatusMethodNotAllowed {
+		t.Error("expected actions to require POST")
+	}
+
+	// step job ids contain "/"
+	started := make(chan struct{})
+
+	blocking, _ := scheduler.NewWorkflow("blocking",
+		scheduler.Step{
+			Name: "wait",
+			Fn: func(ctx context.Context) error {
+				close(started)
+
+				<-ctx.Done()
+
+				return ctx.Err()
+			},
+		},
+	)
+
+	run := s.RunWorkflow(blocking)
+
+	clock.Advance(0)
+	<-started
+
+	response = request(http.MethodGet, "/jobs/" + run + "/wait")
+
+	if response.Code != http.StatusOK || !strings.Contains(response.Body.String(), `"running":1`) {
+		t.Error("expected running step job, got:", response.Code, response.Body.String())
+	}
+
+	if request(http.MethodPost, "/jobs/" + run + "/wait/cancel").Code != http.StatusNoContent {
+		t.Error("expected step job to be cancelled")
+	}
+
+	s.Wait()
+
+	if request(http.MethodGet, "/jobs/" + run + "/wait").Code != http.StatusNotFound {
+		t.Error("expected cancelled step job to be gone")
+	}
+}